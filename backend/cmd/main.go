@@ -1,21 +1,33 @@
 package main
 
 import (
+	"backend/internal/calendar"
 	"backend/pkg/api"
+	"backend/pkg/api/handlers"
+	"backend/pkg/display"
+	"backend/pkg/room"
 	"backend/pkg/serialhandler"
 	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/joho/godotenv"
 )
 
 func main() {
 	// Parse the config path from command-line arguments
 	configPath := flag.String("config", "", "Path to the configuration file")
+	simulate := flag.Bool("simulate", false, "Run against a simulated switcher instead of a serial port")
+	simulateDelay := flag.Duration("simulate-delay", 0, "Delay before every reply of the simulated switcher")
+	simulateErrorRate := flag.Float64("simulate-error-rate", 0, "Fraction of commands the simulated switcher rejects")
+	capturePath := flag.String("capture", "", "Record all serial traffic to this file")
+	replayPath := flag.String("replay", "", "Play back a capture file instead of using a serial port")
+	captureDevice := flag.String("capture-device", "", "Switcher covered by --capture and --replay, the first switcher by default")
 	flag.Parse()
 
 	// Default to the server's directory if no path is provided
@@ -28,33 +40,109 @@ func main() {
 		*configPath = filepath.Join(executableDir, "config.json")
 	}
 
+	// Log the calendar requests to serverlog.txt in the working directory
+	serverLog, err := handlers.OpenServerLog("serverlog.txt")
+	if err != nil {
+		log.Fatalf("Failed to open server log: %v", err)
+	}
+	defer serverLog.Close()
+
 	// Load the config
 	config, err := serialhandler.LoadConfig(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Initialize serial port
-	var port *serialhandler.Port
-	port, err = serialhandler.NewPort(*config)
+	// The calendar credentials come from .env
+	if err := godotenv.Load(); err != nil {
+		log.Printf("No .env file loaded: %v", err)
+	}
+
+	if *simulate {
+		// Fake the network displays, they are reached through config.Display
+		displays := []*serialhandler.Config{config}
+		if len(config.Devices) > 0 {
+			displays = nil
+			for i := range config.Devices {
+				if config.Devices[i].Type == serialhandler.DeviceDisplay {
+					displays = append(displays, &config.Devices[i].Config)
+				}
+			}
+		}
+		for _, displayConfig := range displays {
+			stopDisplay, err := simulateDisplay(displayConfig)
+			if err != nil {
+				log.Fatalf("Failed to start fake display: %v", err)
+			}
+			defer stopDisplay()
+		}
+
+		// Only the devices are simulated, bookings still go to the real calendar
+		log.Printf("Simulating the devices only, the calendar is not simulated")
+	}
+
+	// Capture and replay cover one switcher, the first one (the one the older API talks to) by default
+	captured, ok := config.DeviceByName(*captureDevice, serialhandler.DeviceSwitcher)
+	if (*capturePath != "" || *replayPath != "") && (!ok || captured.Type != serialhandler.DeviceSwitcher) {
+		log.Fatalf("No switcher %q for --capture or --replay", *captureDevice)
+	}
+	var replay serialhandler.Opener
+	if *replayPath != "" {
+		records, err := serialhandler.LoadCapture(*replayPath)
+		if err != nil {
+			log.Fatalf("Failed to load capture: %v", err)
+		}
+		replay = serialhandler.NewReplayer(records).Open
+	}
+	var capture *serialhandler.Capture
+	if *capturePath != "" {
+		capture, err = serialhandler.NewCapture(*capturePath)
+		if err != nil {
+			log.Fatalf("Failed to open capture file: %v", err)
+		}
+		defer capture.Close()
+	}
+	if capture != nil || replay != nil {
+		log.Printf("Capture and replay cover switcher %q", captured.Name)
+	}
+
+	// Pick where the traffic of each switcher goes, the configured transport unless replaying or simulating
+	opener := func(device serialhandler.DeviceConfig) serialhandler.Opener {
+		covered := device.Name == captured.Name
+		open := serialhandler.OpenTransport
+		switch {
+		case replay != nil && covered:
+			open = replay
+		case *simulate:
+			simulator := serialhandler.NewSimulator(device.Config, serialhandler.SimulatorOptions{
+				Delay:     *simulateDelay,
+				ErrorRate: *simulateErrorRate,
+			})
+			open = simulator.Open
+		}
+		if capture != nil && covered {
+			open = capture.Wrap(open)
+		}
+		return open
+	}
+
+	// Connect the switchers and displays of the room, switchers keep reconnecting in the background
+	devices, err := room.Open(config, opener)
 	if err != nil {
-		log.Printf("Failed to initialize serial port: %v", err)
-	} else {
-		defer port.Close()
-		log.Println("Serial port initialized successfully.")
+		log.Fatalf("Failed to initialize devices: %v", err)
 	}
+	defer devices.Close()
+
+	// Tokens are cached and refreshed before they expire
+	meetings, err := calendar.Open(config.Calendar)
+	if err != nil {
+		log.Fatalf("Failed to initialize calendar: %v", err)
+	}
+	defer meetings.Close()
 
 	// Set up Router
 	router := mux.NewRouter()
-	api.SetupRoutes(router, port) // Pass the (potentially nil) port to the API
-
-	// Run startup commands if the serial port is open
-	if port != nil {
-		log.Println("Running startup commands...")
-		port.RunStartupCommands(config.StartupCommands)
-	} else {
-		log.Println("Startup commands skipped because the serial port is not initialized.")
-	}
+	api.SetupRoutes(router, devices, config, meetings)
 
 	// Start the server
 	portStr := strconv.Itoa(config.ServerPort) // Convert integer to string
@@ -63,3 +151,31 @@ func main() {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
+
+// simulateDisplay starts a fake display for the network display drivers and
+// points config.Display at it
+func simulateDisplay(config *serialhandler.Config) (func(), error) {
+	var fake interface {
+		Addr() string
+		Close() error
+	}
+	var err error
+
+	switch config.Display.Driver {
+	case "pjlink":
+		fake, err = display.NewFakePJLinkServer("127.0.0.1:0", config.Display.Password)
+	case "samsung_mdc", "lg":
+		fake, err = display.NewFakeDisplayServer(config.Display.Driver, "127.0.0.1:0")
+	default:
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	host, port, _ := net.SplitHostPort(fake.Addr())
+	config.Display.Device = ""
+	config.Display.Host = host
+	config.Display.Port, _ = strconv.Atoi(port)
+	return func() { fake.Close() }, nil
+}
@@ -4,12 +4,11 @@ go 1.23.4
 
 require (
 	github.com/gorilla/mux v1.8.1
+	github.com/joho/godotenv v1.5.1
 	go.bug.st/serial v1.6.2
 )
 
 require (
 	github.com/creack/goselect v0.1.2 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/linde12/gowol v0.0.0-20180926075039-797e4d01634c // indirect
 	golang.org/x/sys v0.28.0 // indirect
 )
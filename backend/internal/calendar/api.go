@@ -0,0 +1,87 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiClient sends JSON requests with a bearer token to a REST API, the
+// transport of the Graph and Google providers
+type apiClient struct {
+	Tokens  TokenSource
+	BaseURL string
+	Client  *http.Client
+	// header is set on every request, e.g. Graph's Prefer
+	header map[string]string
+}
+
+func newAPIClient(tokens TokenSource, baseURL string) apiClient {
+	return apiClient{Tokens: tokens, BaseURL: strings.TrimSuffix(baseURL, "/"), Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// apiError is a reply other than the one expected, with its body for the caller to tell errors apart
+type apiError struct {
+	Status     string
+	StatusCode int
+	Body       string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s, response: %s", e.Status, e.Body)
+}
+
+// do sends body as JSON and decodes the reply into out, a reply other than want is an *apiError
+func (c *apiClient) do(method, requestURL string, body, out any, want int) error {
+	accessToken, err := c.Tokens.Token()
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, requestURL, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range c.header {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		data, _ := io.ReadAll(resp.Body)
+		return &apiError{Status: resp.Status, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// Close stops refreshing the access token in the background
+func (c *apiClient) Close() {
+	if closer, ok := c.Tokens.(interface{ Close() }); ok {
+		closer.Close()
+	}
+}
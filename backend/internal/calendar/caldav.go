@@ -0,0 +1,274 @@
+package calendar
+
+import (
+	"backend/pkg/serialhandler"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CalDAVProvider reads and books meetings in a CalDAV calendar collection,
+// e.g. a Nextcloud room resource. Event IDs are the URLs of the calendar
+// objects, so they can be updated and deleted.
+type CalDAVProvider struct {
+	// URL is the calendar collection, e.g. https://cloud.example.com/remote.php/dav/calendars/room/personal/
+	URL string
+	// Username and Password are sent as basic auth, Token as a bearer token
+	Username string
+	Password string
+	Token    string
+	Client   *http.Client
+}
+
+// NewCalDAVProvider returns a provider for the calendar collection at collectionURL
+func NewCalDAVProvider(collectionURL string) *CalDAVProvider {
+	if !strings.HasSuffix(collectionURL, "/") {
+		collectionURL += "/"
+	}
+	return &CalDAVProvider{URL: collectionURL, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// newCalDAVProviderFromEnv logs in with CALDAV_USERNAME and CALDAV_PASSWORD, or CALDAV_TOKEN
+func newCalDAVProviderFromEnv(config serialhandler.CalendarConfig) (CalendarProvider, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("the caldav calendar provider needs a url")
+	}
+	provider := NewCalDAVProvider(config.URL)
+	provider.Username = os.Getenv("CALDAV_USERNAME")
+	provider.Password = os.Getenv("CALDAV_PASSWORD")
+	provider.Token = os.Getenv("CALDAV_TOKEN")
+	return provider, nil
+}
+
+// calendarQuery is the REPORT body asking for the events overlapping a time range
+const calendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="%s" end="%s"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`
+
+// multistatus is the 207 reply of a REPORT
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				CalendarData string `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// ListEvents runs a calendar-query REPORT for start to end and expands the
+// returned calendar objects. The room is set by the collection URL.
+func (p *CalDAVProvider) ListEvents(room string, start, end time.Time) ([]Event, error) {
+	const utc = "20060102T150405Z"
+	body := fmt.Sprintf(calendarQuery, start.UTC().Format(utc), end.UTC().Format(utc))
+	resp, err := p.do("REPORT", p.URL, "application/xml; charset=utf-8", body, map[string]string{"Depth": "1"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query calendar: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("failed to query calendar: %w", statusError(resp))
+	}
+
+	var result multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode calendar query: %w", err)
+	}
+
+	var events []Event
+	for _, response := range result.Responses {
+		href, err := p.resolve(response.Href)
+		if err != nil {
+			return nil, err
+		}
+		for _, propstat := range response.Propstat {
+			if propstat.Prop.CalendarData == "" || !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			calendar, err := parseICS(strings.NewReader(propstat.Prop.CalendarData))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", href, err)
+			}
+			for _, event := range icsEvents(calendar, start, end) {
+				event.ID = href
+				events = append(events, event)
+			}
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+// CreateEvent stores event as a new calendar object with PUT
+func (p *CalDAVProvider) CreateEvent(room string, event Event) (*Event, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	uid := hex.EncodeToString(random)
+	href := p.URL + uid + ".ics"
+	if err := p.put(href, formatICS(uid, event), map[string]string{"If-None-Match": "*"}); err != nil {
+		return nil, fmt.Errorf("failed to create event: %w", err)
+	}
+	event.ID = href
+	return &event, nil
+}
+
+// UpdateEvent changes the times and subject of the calendar object event.ID.
+// The object is read first and only those lines are replaced, the object is
+// only written back if nobody changed it in between. Occurrences of recurring
+// events share their object, they are not changed.
+func (p *CalDAVProvider) UpdateEvent(room string, event Event) (*Event, error) {
+	if event.ID == "" {
+		return nil, fmt.Errorf("failed to update event: no event ID")
+	}
+	data, headers, err := p.get(event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	updated, err := updateICS(data, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update event %s: %w", event.ID, err)
+	}
+	if err := p.put(event.ID, updated, headers); err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	return &event, nil
+}
+
+// get reads the calendar object at href and returns it with the If-Match
+// header for writing it back, if the server sent an etag
+func (p *CalDAVProvider) get(href string) (string, map[string]string, error) {
+	resp, err := p.do("GET", href, "", "", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, statusError(resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", href, err)
+	}
+	var headers map[string]string
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		headers = map[string]string{"If-Match": etag}
+	}
+	return string(data), headers, nil
+}
+
+// DeleteEvent removes the calendar object id, unless it is a recurring event
+func (p *CalDAVProvider) DeleteEvent(room string, id string) error {
+	data, headers, err := p.get(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	calendar, err := parseICS(strings.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", id, err)
+	}
+	if _, err := singleEvent(calendar); err != nil {
+		return fmt.Errorf("failed to delete event %s: %w", id, err)
+	}
+
+	resp, err := p.do("DELETE", id, "", "", headers)
+	if err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf("failed to delete event: %s was changed by someone else: %w", id, statusError(resp))
+	default:
+		return fmt.Errorf("failed to delete event: %w", statusError(resp))
+	}
+}
+
+// put writes the calendar object data to href
+func (p *CalDAVProvider) put(href, data string, headers map[string]string) error {
+	resp, err := p.do("PUT", href, "text/calendar; charset=utf-8", data, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf("%s was changed by someone else: %w", href, statusError(resp))
+	default:
+		return statusError(resp)
+	}
+}
+
+// resolve turns the href of a REPORT response into an absolute URL
+func (p *CalDAVProvider) resolve(href string) (string, error) {
+	base, err := url.Parse(p.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid calendar url: %w", err)
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", fmt.Errorf("invalid href %q: %w", href, err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// do sends an authenticated request, the caller closes the body
+func (p *CalDAVProvider) do(method, requestURL, contentType, body string, headers map[string]string) (*http.Response, error) {
+	var reader io.Reader
+	if body != "" {
+		reader = bytes.NewBufferString(body)
+	}
+	req, err := http.NewRequest(method, requestURL, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	switch {
+	case p.Token != "":
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	case p.Username != "":
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// statusError reports an unexpected reply with its body
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("%s, response: %s", resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,217 @@
+package calendar
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeCalDAVServer is a local stand-in for a CalDAV server with one calendar
+// collection, so the caldav provider can be tested without Nextcloud. It
+// answers calendar-query REPORTs with a time-range filter, PUT, GET and
+// DELETE, with etags checked by If-Match.
+type FakeCalDAVServer struct {
+	listener net.Listener
+	server   *http.Server
+	// Username and Password require basic auth, Token a bearer token
+	Username string
+	Password string
+	Token    string
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	// changes numbers the etags
+	changes int
+}
+
+// fakeObject is a stored calendar object
+type fakeObject struct {
+	data string
+	etag string
+}
+
+// NewFakeCalDAVServer listens on address ("127.0.0.1:0" for any free port)
+func NewFakeCalDAVServer(address string) (*FakeCalDAVServer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FakeCalDAVServer{listener: listener, objects: map[string]fakeObject{}}
+	s.server = &http.Server{Handler: http.HandlerFunc(s.handle)}
+	go s.server.Serve(listener)
+	log.Printf("Fake CalDAV server listening on %s", listener.Addr())
+	return s, nil
+}
+
+// URL returns the address of the calendar collection
+func (s *FakeCalDAVServer) URL() string {
+	return fmt.Sprintf("http://%s/calendars/room/", s.listener.Addr())
+}
+
+// AddEvent stores event as a calendar object, as if booked by someone else
+func (s *FakeCalDAVServer) AddEvent(uid string, event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store("/calendars/room/"+uid+".ics", formatICS(uid, event))
+}
+
+// AddObject stores a calendar object as is under name, e.g. a recurring
+// event written by another client
+func (s *FakeCalDAVServer) AddObject(name, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store("/calendars/room/"+name, data)
+}
+
+// Object returns the calendar object stored under name
+func (s *FakeCalDAVServer) Object(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	object, ok := s.objects["/calendars/room/"+name]
+	return object.data, ok
+}
+
+// store saves an object with a new etag, must be called with s.mu held
+func (s *FakeCalDAVServer) store(path, data string) string {
+	s.changes++
+	etag := fmt.Sprintf(`"%d"`, s.changes)
+	s.objects[path] = fakeObject{data: data, etag: etag}
+	return etag
+}
+
+// preconditionFailed checks the If-Match header against the object at path
+func (s *FakeCalDAVServer) preconditionFailed(r *http.Request) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return false
+	}
+	object, ok := s.objects[r.URL.Path]
+	return !ok || ifMatch != "*" && ifMatch != object.etag
+}
+
+// Close stops the server
+func (s *FakeCalDAVServer) Close() error {
+	return s.server.Close()
+}
+
+func (s *FakeCalDAVServer) authorized(r *http.Request) bool {
+	if s.Token != "" {
+		return r.Header.Get("Authorization") == "Bearer "+s.Token
+	}
+	if s.Username != "" {
+		username, password, ok := r.BasicAuth()
+		return ok && username == s.Username && password == s.Password
+	}
+	return true
+}
+
+func (s *FakeCalDAVServer) handle(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="fake caldav"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case "REPORT":
+		s.report(w, r)
+	case "PUT":
+		body, _ := io.ReadAll(r.Body)
+		if _, err := parseICS(strings.NewReader(string(body))); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, exists := s.objects[r.URL.Path]
+		if exists && r.Header.Get("If-None-Match") == "*" || s.preconditionFailed(r) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		w.Header().Set("ETag", s.store(r.URL.Path, string(body)))
+		if exists {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case "GET":
+		object, ok := s.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("ETag", object.etag)
+		io.WriteString(w, object.data)
+	case "DELETE":
+		if _, ok := s.objects[r.URL.Path]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if s.preconditionFailed(r) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// report answers a calendar-query with the objects that have an event in its time range
+func (s *FakeCalDAVServer) report(w http.ResponseWriter, r *http.Request) {
+	var query struct {
+		TimeRange struct {
+			Start string `xml:"start,attr"`
+			End   string `xml:"end,attr"`
+		} `xml:"filter>comp-filter>comp-filter>time-range"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	start, err1 := time.Parse("20060102T150405Z", query.TimeRange.Start)
+	end, err2 := time.Parse("20060102T150405Z", query.TimeRange.End)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "invalid time-range", http.StatusBadRequest)
+		return
+	}
+
+	paths := make([]string, 0, len(s.objects))
+	for path := range s.objects {
+		if strings.HasPrefix(path, r.URL.Path) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var reply strings.Builder
+	reply.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n" + `<d:multistatus xmlns:d="DAV:" xmlns:cal="urn:ietf:params:xml:ns:caldav">`)
+	for _, path := range paths {
+		object := s.objects[path]
+		calendar, err := parseICS(strings.NewReader(object.data))
+		if err != nil || len(icsEvents(calendar, start, end)) == 0 {
+			continue
+		}
+		reply.WriteString("<d:response><d:href>" + path + "</d:href><d:propstat><d:prop><d:getetag>")
+		xml.EscapeText(&reply, []byte(object.etag))
+		reply.WriteString("</d:getetag><cal:calendar-data>")
+		xml.EscapeText(&reply, []byte(object.data))
+		reply.WriteString("</cal:calendar-data></d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>")
+	}
+	reply.WriteString("</d:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, reply.String())
+}
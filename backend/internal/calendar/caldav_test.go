@@ -0,0 +1,215 @@
+package calendar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeCalDAV starts a fake server requiring basic auth and a provider logged in to it
+func newFakeCalDAV(t *testing.T) (*CalDAVProvider, *FakeCalDAVServer) {
+	t.Helper()
+	server, err := NewFakeCalDAVServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start fake CalDAV server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	server.Username, server.Password = "room", "app-password"
+
+	provider := NewCalDAVProvider(server.URL())
+	provider.Username, provider.Password = "room", "app-password"
+	return provider, server
+}
+
+func TestCalDAVBookingLifecycle(t *testing.T) {
+	provider, server := newFakeCalDAV(t)
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	server.AddEvent("planning", Event{Subject: "Planning", Start: day.Add(9 * time.Hour), End: day.Add(10 * time.Hour)})
+
+	created, err := provider.CreateEvent("room", Event{Subject: "Ad hoc", Start: day.Add(11 * time.Hour), End: day.Add(11*time.Hour + 30*time.Minute)})
+	if err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	if !strings.HasPrefix(created.ID, server.URL()) {
+		t.Errorf("event ID = %q, want a URL in the collection", created.ID)
+	}
+
+	events, err := provider.ListEvents("room", day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 2 || events[0].Subject != "Planning" || events[1].ID != created.ID {
+		t.Fatalf("events = %+v, want planning and the booking", events)
+	}
+
+	if err := provider.DeleteEvent("room", created.ID); err != nil {
+		t.Fatalf("DeleteEvent: %v", err)
+	}
+	if events, _ := provider.ListEvents("room", day, day.Add(24*time.Hour)); len(events) != 1 {
+		t.Errorf("events after delete = %+v, want only planning", events)
+	}
+	if err := provider.DeleteEvent("room", created.ID); err == nil {
+		t.Error("deleting a deleted event succeeded")
+	}
+}
+
+func TestCalDAVUpdateKeepsTheObject(t *testing.T) {
+	provider, server := newFakeCalDAV(t)
+	// Written by another client: the file name is not the UID and the event
+	// has attendees, an alarm and its own time zone
+	server.AddObject("booking-42.ics", strings.ReplaceAll(`BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VTIMEZONE
+TZID:Europe/Berlin
+BEGIN:STANDARD
+DTSTART:19701025T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;BYDAY=-1SU;BYMONTH=10
+END:STANDARD
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:040000008200E00074C5B7101A82E008@example.com
+SUMMARY:Review
+DTSTART;TZID=Europe/Berlin:20260302T140000
+DURATION:PT1H
+LOCATION:Room 2
+ORGANIZER:mailto:anna@example.com
+ATTENDEE;CN=Bo;ROLE=REQ-PARTICIPANT:mailto:bo@example.c
+ om
+BEGIN:VALARM
+ACTION:DISPLAY
+SUMMARY:Reminder
+TRIGGER:-PT10M
+END:VALARM
+END:VEVENT
+END:VCALENDAR
+`, "\n", "\r\n"))
+
+	id := server.URL() + "booking-42.ics"
+	start := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	if _, err := provider.UpdateEvent("room", Event{ID: id, Subject: "Review (moved)", Start: start, End: start.Add(time.Hour)}); err != nil {
+		t.Fatalf("UpdateEvent: %v", err)
+	}
+
+	object, _ := server.Object("booking-42.ics")
+	for _, line := range []string{
+		"UID:040000008200E00074C5B7101A82E008@example.com",
+		"TZID:Europe/Berlin",
+		"LOCATION:Room 2",
+		"ORGANIZER:mailto:anna@example.com",
+		"ATTENDEE;CN=Bo;ROLE=REQ-PARTICIPANT:mailto:bo@example.c\r\n om",
+		"SUMMARY:Reminder",
+		"DTSTART:20260302T150000Z",
+		"DTEND:20260302T160000Z",
+		"SUMMARY:Review (moved)",
+	} {
+		if !strings.Contains(object, line+"\r\n") {
+			t.Errorf("updated object has no %q:\n%s", line, object)
+		}
+	}
+	if strings.Contains(object, "DURATION") || strings.Contains(object, "T140000") {
+		t.Errorf("updated object kept the old times:\n%s", object)
+	}
+
+	if _, err := provider.UpdateEvent("room", Event{ID: server.URL() + "missing.ics", Start: start, End: start.Add(time.Hour)}); err == nil {
+		t.Error("updating a missing event succeeded")
+	}
+}
+
+func TestCalDAVLeavesRecurringObjects(t *testing.T) {
+	provider, server := newFakeCalDAV(t)
+	standup := strings.ReplaceAll(`BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:standup
+SUMMARY:Standup
+DTSTART:20260302T080000Z
+DTEND:20260302T081500Z
+RRULE:FREQ=DAILY
+EXDATE:20260304T080000Z
+END:VEVENT
+END:VCALENDAR
+`, "\n", "\r\n")
+	server.AddObject("standup.ics", standup)
+
+	day := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	events, err := provider.ListEvents("room", day, day.Add(24*time.Hour))
+	if err != nil || len(events) != 1 {
+		t.Fatalf("ListEvents = %+v, %v, want the occurrence on the 5th", events, err)
+	}
+
+	occurrence := events[0]
+	occurrence.Start = occurrence.Start.Add(time.Hour)
+	occurrence.End = occurrence.End.Add(time.Hour)
+	if _, err := provider.UpdateEvent("room", occurrence); !errors.Is(err, errRecurringEvent) {
+		t.Errorf("UpdateEvent on an occurrence error = %v, want errRecurringEvent", err)
+	}
+	if err := provider.DeleteEvent("room", occurrence.ID); !errors.Is(err, errRecurringEvent) {
+		t.Errorf("DeleteEvent on an occurrence error = %v, want errRecurringEvent", err)
+	}
+	if object, _ := server.Object("standup.ics"); object != standup {
+		t.Errorf("the series was changed:\n%s", object)
+	}
+}
+
+func TestCalDAVPutChecksETag(t *testing.T) {
+	provider, server := newFakeCalDAV(t)
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	server.AddEvent("planning", Event{Subject: "Planning", Start: start, End: start.Add(time.Hour)})
+	id := server.URL() + "planning.ics"
+
+	data, headers, err := provider.get(id)
+	if err != nil || headers["If-Match"] == "" {
+		t.Fatalf("get = %v, %v, want an etag", headers, err)
+	}
+	// Someone else changes the event after it was read
+	server.AddEvent("planning", Event{Subject: "Planning (moved)", Start: start.Add(time.Hour), End: start.Add(2 * time.Hour)})
+
+	err = provider.put(id, data, headers)
+	if err == nil || !strings.Contains(err.Error(), "changed by someone else") {
+		t.Errorf("put with a stale etag error = %v, want a conflict", err)
+	}
+	if object, _ := server.Object("planning.ics"); !strings.Contains(object, "Planning (moved)") {
+		t.Error("the stale update overwrote the other change")
+	}
+}
+
+func TestCalDAVExpandsRecurringObjects(t *testing.T) {
+	provider, server := newFakeCalDAV(t)
+	server.AddObject("standup.ics", strings.ReplaceAll(`BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:standup
+SUMMARY:Standup
+DTSTART;TZID=Europe/Berlin:20250106T090000
+DTEND;TZID=Europe/Berlin:20250106T091500
+RRULE:FREQ=WEEKLY;BYDAY=MO,TH
+END:VEVENT
+END:VCALENDAR
+`, "\n", "\r\n"))
+
+	day := time.Date(2026, 7, 2, 0, 0, 0, 0, time.UTC) // a Thursday
+	events, err := provider.ListEvents("room", day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 1 || !events[0].Start.Equal(day.Add(7*time.Hour)) || events[0].ID != server.URL()+"standup.ics" {
+		t.Errorf("events = %+v, want the 09:00 Berlin occurrence", events)
+	}
+}
+
+func TestCalDAVAuthentication(t *testing.T) {
+	provider, _ := newFakeCalDAV(t)
+	provider.Password = "wrong"
+	_, err := provider.ListEvents("room", time.Now(), time.Now().Add(time.Hour))
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("ListEvents with a wrong password error = %v, want 401", err)
+	}
+
+	provider.Username, provider.Password, provider.Token = "", "", "token"
+	if _, err := provider.ListEvents("room", time.Now(), time.Now().Add(time.Hour)); err == nil {
+		t.Error("ListEvents with a token the server does not expect succeeded")
+	}
+}
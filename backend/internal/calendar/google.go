@@ -0,0 +1,236 @@
+package calendar
+
+import (
+	"backend/pkg/serialhandler"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// googleURL is the Google Calendar API used when the config sets no url
+const googleURL = "https://www.googleapis.com/calendar/v3"
+
+// errNoEventAccess is returned by the events API when the account may only see free/busy
+var errNoEventAccess = errors.New("no access to the event details")
+
+// GoogleProvider reads the calendar of a Google Workspace room resource. The
+// room is the resource's calendar ID, e.g. c_1888abc@resource.calendar.google.com.
+type GoogleProvider struct {
+	apiClient
+}
+
+// NewGoogleProvider returns a provider for the API at baseURL, googleapis.com if empty
+func NewGoogleProvider(tokens TokenSource, baseURL string) *GoogleProvider {
+	if baseURL == "" {
+		baseURL = googleURL
+	}
+	return &GoogleProvider{apiClient: newAPIClient(tokens, baseURL)}
+}
+
+func newGoogleProviderFromConfig(config serialhandler.CalendarConfig) (CalendarProvider, error) {
+	if config.CredentialsFile == "" {
+		return nil, fmt.Errorf("the google calendar provider needs a credentials_file")
+	}
+	account, err := LoadServiceAccount(config.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	issue, err := ServiceAccountTokenIssuer(account, config.Subject, config.TokenURL)
+	if err != nil {
+		return nil, err
+	}
+	return NewGoogleProvider(NewCachedTokenSource(issue), config.URL), nil
+}
+
+// googleTime is the start or end of a Google event, date is set for all-day events
+type googleTime struct {
+	DateTime string `json:"dateTime,omitempty"`
+	Date     string `json:"date,omitempty"`
+}
+
+type googleEvent struct {
+	ID      string     `json:"id,omitempty"`
+	Summary string     `json:"summary"`
+	Status  string     `json:"status,omitempty"`
+	Start   googleTime `json:"start"`
+	End     googleTime `json:"end"`
+}
+
+func newGoogleEvent(event Event) googleEvent {
+	return googleEvent{
+		Summary: event.Subject,
+		Start:   googleTime{DateTime: event.Start.Format(time.RFC3339)},
+		End:     googleTime{DateTime: event.End.Format(time.RFC3339)},
+	}
+}
+
+func (e googleEvent) event() (*Event, error) {
+	start, err := e.Start.time()
+	if err != nil {
+		return nil, err
+	}
+	end, err := e.End.time()
+	if err != nil {
+		return nil, err
+	}
+	return &Event{ID: e.ID, Subject: e.Summary, Start: start, End: end}, nil
+}
+
+// time parses the dateTime, all-day dates start at midnight in the server's zone
+func (t googleTime) time() (time.Time, error) {
+	if t.Date != "" {
+		return time.ParseInLocation("2006-01-02", t.Date, time.Local)
+	}
+	return time.Parse(time.RFC3339, t.DateTime)
+}
+
+// ListEvents reads the events of room with recurring events expanded. When
+// the service account may only see free/busy, the busy periods are returned
+// as events without a subject.
+func (g *GoogleProvider) ListEvents(room string, start, end time.Time) ([]Event, error) {
+	events, err := g.listEvents(room, start, end)
+	if errors.Is(err, errNoEventAccess) {
+		return g.freeBusy(room, start, end)
+	}
+	return events, err
+}
+
+func (g *GoogleProvider) listEvents(room string, start, end time.Time) ([]Event, error) {
+	query := url.Values{}
+	query.Set("timeMin", start.Format(time.RFC3339))
+	query.Set("timeMax", end.Format(time.RFC3339))
+	query.Set("singleEvents", "true")
+	query.Set("orderBy", "startTime")
+
+	var events []Event
+	for {
+		var page struct {
+			Items         []googleEvent `json:"items"`
+			NextPageToken string        `json:"nextPageToken"`
+		}
+		err := g.do("GET", g.eventsURL(room, "")+"?"+query.Encode(), nil, &page, http.StatusOK)
+		if noEventAccess(err) {
+			return nil, errNoEventAccess
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch events: %w", err)
+		}
+		for _, item := range page.Items {
+			if item.Status == "cancelled" {
+				continue
+			}
+			event, err := item.event()
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse event %s: %w", item.ID, err)
+			}
+			events = append(events, *event)
+		}
+		if page.NextPageToken == "" {
+			return events, nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// accessReasons are the reasons of a 403 from the events API meaning the
+// account may not read the events, unlike rate limits which are also a 403.
+// A missing calendar is a 404 "notFound" and stays an error.
+var accessReasons = map[string]bool{
+	"forbidden":               true,
+	"requiredAccessLevel":     true,
+	"insufficientPermissions": true,
+}
+
+// noEventAccess tells if err is the events API refusing access to the event details
+func noEventAccess(err error) bool {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		return false
+	}
+	var reply struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(apiErr.Body), &reply) != nil || len(reply.Error.Errors) == 0 {
+		return false
+	}
+	return accessReasons[reply.Error.Errors[0].Reason]
+}
+
+// freeBusy asks the freeBusy API for the busy periods of room
+func (g *GoogleProvider) freeBusy(room string, start, end time.Time) ([]Event, error) {
+	request := map[string]any{
+		"timeMin": start.Format(time.RFC3339),
+		"timeMax": end.Format(time.RFC3339),
+		"items":   []map[string]string{{"id": room}},
+	}
+	var reply struct {
+		Calendars map[string]struct {
+			Busy []struct {
+				Start time.Time `json:"start"`
+				End   time.Time `json:"end"`
+			} `json:"busy"`
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"calendars"`
+	}
+	if err := g.do("POST", g.BaseURL+"/freeBusy", request, &reply, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to fetch free/busy: %w", err)
+	}
+
+	calendar, ok := reply.Calendars[room]
+	if !ok {
+		return nil, fmt.Errorf("failed to fetch free/busy: no reply for %s", room)
+	}
+	if len(calendar.Errors) > 0 {
+		return nil, fmt.Errorf("failed to fetch free/busy for %s: %s", room, calendar.Errors[0].Reason)
+	}
+	var events []Event
+	for _, busy := range calendar.Busy {
+		events = append(events, Event{Start: busy.Start, End: busy.End})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+// CreateEvent books event in the calendar of room
+func (g *GoogleProvider) CreateEvent(room string, event Event) (*Event, error) {
+	var created googleEvent
+	if err := g.do("POST", g.eventsURL(room, ""), newGoogleEvent(event), &created, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to create event: %w", err)
+	}
+	return created.event()
+}
+
+// UpdateEvent changes the subject and times of event.ID
+func (g *GoogleProvider) UpdateEvent(room string, event Event) (*Event, error) {
+	var updated googleEvent
+	if err := g.do("PATCH", g.eventsURL(room, event.ID), newGoogleEvent(event), &updated, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	return updated.event()
+}
+
+// DeleteEvent removes the event with id from the calendar of room
+func (g *GoogleProvider) DeleteEvent(room string, id string) error {
+	if err := g.do("DELETE", g.eventsURL(room, id), nil, nil, http.StatusNoContent); err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	return nil
+}
+
+func (g *GoogleProvider) eventsURL(room, id string) string {
+	eventsURL := fmt.Sprintf("%s/calendars/%s/events", g.BaseURL, url.PathEscape(room))
+	if id != "" {
+		eventsURL += "/" + url.PathEscape(id)
+	}
+	return eventsURL
+}
@@ -0,0 +1,81 @@
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// googleServer answers the events API with status and reason, and the freeBusy API with one busy period
+func googleServer(t *testing.T, status int, reason string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer google-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/freeBusy":
+			fmt.Fprint(w, `{"calendars":{"room@example.com":{"busy":[{"start":"2026-03-02T09:00:00Z","end":"2026-03-02T10:00:00Z"}]}}}`)
+		case status != http.StatusOK:
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"error":{"errors":[{"domain":"global","reason":%q}],"code":%d}}`, reason, status)
+		default:
+			json.NewEncoder(w).Encode(map[string]any{"items": []googleEvent{
+				{ID: "1", Summary: "Standup", Start: googleTime{DateTime: "2026-03-02T09:00:00Z"}, End: googleTime{DateTime: "2026-03-02T09:15:00Z"}},
+				{ID: "2", Summary: "Moved", Status: "cancelled"},
+			}})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGoogleListEvents(t *testing.T) {
+	server := googleServer(t, http.StatusOK, "")
+	provider := NewGoogleProvider(staticToken("google-token"), server.URL)
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	events, err := provider.ListEvents("room@example.com", start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 1 || events[0].Subject != "Standup" {
+		t.Errorf("events = %+v, want the standup without the cancelled event", events)
+	}
+}
+
+func TestGoogleFreeBusyFallback(t *testing.T) {
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status   int
+		reason   string
+		fallback bool
+	}{
+		{http.StatusForbidden, "forbidden", true},
+		{http.StatusForbidden, "requiredAccessLevel", true},
+		{http.StatusForbidden, "rateLimitExceeded", false},
+		{http.StatusForbidden, "userRateLimitExceeded", false},
+		{http.StatusForbidden, "insufficientPermissions", true},
+		{http.StatusForbidden, "notFound", false},
+		{http.StatusNotFound, "notFound", false},
+		{http.StatusInternalServerError, "backendError", false},
+	}
+	for _, test := range tests {
+		server := googleServer(t, test.status, test.reason)
+		provider := NewGoogleProvider(staticToken("google-token"), server.URL)
+		events, err := provider.ListEvents("room@example.com", start, start.Add(24*time.Hour))
+		if test.fallback {
+			if err != nil || len(events) != 1 || events[0].Subject != "" || !events[0].Start.Equal(start.Add(9*time.Hour)) {
+				t.Errorf("%d %s: events = %+v, %v, want the busy period", test.status, test.reason, events, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%d %s: error = %v, want the events API error", test.status, test.reason, err)
+		}
+	}
+}
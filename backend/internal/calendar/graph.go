@@ -0,0 +1,143 @@
+package calendar
+
+import (
+	"backend/pkg/serialhandler"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// graphURL is the Microsoft Graph endpoint used when the config sets no url
+const graphURL = "https://graph.microsoft.com/v1.0"
+
+// graphTimeFormat is the dateTime format of Graph, the zone goes in timeZone
+const graphTimeFormat = "2006-01-02T15:04:05"
+
+// GraphProvider reads the room mailbox calendar through Microsoft Graph
+type GraphProvider struct {
+	apiClient
+}
+
+// NewGraphProvider returns a Graph provider for the API at baseURL, graph.microsoft.com if empty
+func NewGraphProvider(tokens TokenSource, baseURL string) *GraphProvider {
+	if baseURL == "" {
+		baseURL = graphURL
+	}
+	client := newAPIClient(tokens, baseURL)
+	// Event times come back in UTC instead of the mailbox's zone
+	client.header = map[string]string{"Prefer": `outlook.timezone="UTC"`}
+	return &GraphProvider{apiClient: client}
+}
+
+// newGraphProviderFromEnv uses the app registration in CLIENT_ID, CLIENT_SECRET and TENANT_ID
+func newGraphProviderFromEnv(config serialhandler.CalendarConfig) (CalendarProvider, error) {
+	issue := GraphTokenIssuer(os.Getenv("CLIENT_ID"), os.Getenv("CLIENT_SECRET"), os.Getenv("TENANT_ID"))
+	return NewGraphProvider(NewCachedTokenSource(issue), config.URL), nil
+}
+
+// graphTime is the start or end of a Graph event
+type graphTime struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+type graphEvent struct {
+	ID      string    `json:"id,omitempty"`
+	Subject string    `json:"subject"`
+	Start   graphTime `json:"start"`
+	End     graphTime `json:"end"`
+}
+
+func newGraphEvent(event Event) graphEvent {
+	return graphEvent{
+		Subject: event.Subject,
+		Start:   graphTime{DateTime: event.Start.UTC().Format(graphTimeFormat), TimeZone: "UTC"},
+		End:     graphTime{DateTime: event.End.UTC().Format(graphTimeFormat), TimeZone: "UTC"},
+	}
+}
+
+func (e graphEvent) event() (*Event, error) {
+	start, err := parseGraphTime(e.Start.DateTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse start time %q: %w", e.Start.DateTime, err)
+	}
+	end, err := parseGraphTime(e.End.DateTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse end time %q: %w", e.End.DateTime, err)
+	}
+	return &Event{ID: e.ID, Subject: e.Subject, Start: start, End: end}, nil
+}
+
+// parseGraphTime parses a dateTime, which is in UTC as requested with the Prefer header
+func parseGraphTime(value string) (time.Time, error) {
+	if !strings.HasSuffix(value, "Z") && !strings.Contains(value, "+") {
+		value += "Z"
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// ListEvents reads the calendarView of room, following the result pages
+func (g *GraphProvider) ListEvents(room string, start, end time.Time) ([]Event, error) {
+	query := url.Values{}
+	query.Set("startDateTime", start.Format(time.RFC3339))
+	query.Set("endDateTime", end.Format(time.RFC3339))
+	query.Set("$select", "id,subject,start,end")
+	next := fmt.Sprintf("%s/users/%s/calendarView?%s", g.BaseURL, url.PathEscape(room), query.Encode())
+
+	var events []Event
+	for next != "" {
+		var page struct {
+			Value    []graphEvent `json:"value"`
+			NextLink string       `json:"@odata.nextLink"`
+		}
+		if err := g.do("GET", next, nil, &page, http.StatusOK); err != nil {
+			return nil, fmt.Errorf("failed to fetch calendar view: %w", err)
+		}
+		for _, item := range page.Value {
+			event, err := item.event()
+			if err != nil {
+				return nil, err
+			}
+			events = append(events, *event)
+		}
+		next = page.NextLink
+	}
+	return events, nil
+}
+
+// CreateEvent books event in the calendar of room
+func (g *GraphProvider) CreateEvent(room string, event Event) (*Event, error) {
+	var created graphEvent
+	if err := g.do("POST", g.eventsURL(room, ""), newGraphEvent(event), &created, http.StatusCreated); err != nil {
+		return nil, fmt.Errorf("failed to create event: %w", err)
+	}
+	return created.event()
+}
+
+// UpdateEvent changes the subject and times of event.ID
+func (g *GraphProvider) UpdateEvent(room string, event Event) (*Event, error) {
+	var updated graphEvent
+	if err := g.do("PATCH", g.eventsURL(room, event.ID), newGraphEvent(event), &updated, http.StatusOK); err != nil {
+		return nil, fmt.Errorf("failed to update event: %w", err)
+	}
+	return updated.event()
+}
+
+// DeleteEvent removes the event with id from the calendar of room
+func (g *GraphProvider) DeleteEvent(room string, id string) error {
+	if err := g.do("DELETE", g.eventsURL(room, id), nil, nil, http.StatusNoContent); err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+	return nil
+}
+
+func (g *GraphProvider) eventsURL(room, id string) string {
+	eventsURL := fmt.Sprintf("%s/users/%s/events", g.BaseURL, url.PathEscape(room))
+	if id != "" {
+		eventsURL += "/" + url.PathEscape(id)
+	}
+	return eventsURL
+}
@@ -0,0 +1,96 @@
+package calendar
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// staticToken is a TokenSource that always returns the same token
+type staticToken string
+
+func (t staticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+func TestGraphListEventsFollowsPages(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer graph-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Prefer") != `outlook.timezone="UTC"` {
+			t.Errorf("Prefer header = %q", r.Header.Get("Prefer"))
+		}
+		if r.URL.Path != "/users/room@example.com/calendarView" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		page := map[string]any{}
+		if r.URL.Query().Get("page") == "" {
+			page["value"] = []graphEvent{{ID: "1", Subject: "Standup", Start: graphTime{DateTime: "2026-03-02T09:00:00.0000000"}, End: graphTime{DateTime: "2026-03-02T09:15:00.0000000"}}}
+			page["@odata.nextLink"] = server.URL + "/users/room@example.com/calendarView?page=2"
+		} else {
+			page["value"] = []graphEvent{{ID: "2", Subject: "Review", Start: graphTime{DateTime: "2026-03-02T14:00:00"}, End: graphTime{DateTime: "2026-03-02T15:00:00"}}}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	provider := NewGraphProvider(staticToken("graph-token"), server.URL)
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	events, err := provider.ListEvents("room@example.com", start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	if len(events) != 2 || events[0].Subject != "Standup" || events[1].ID != "2" {
+		t.Fatalf("events = %+v, want both pages", events)
+	}
+	if want := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC); !events[0].Start.Equal(want) {
+		t.Errorf("start = %s, want %s", events[0].Start, want)
+	}
+}
+
+func TestGraphCreateEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/users/room@example.com/events" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var event graphEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		if event.Start.TimeZone != "UTC" || event.Start.DateTime != "2026-03-02T10:00:00" {
+			t.Errorf("start = %+v, want 10:00 UTC", event.Start)
+		}
+		event.ID = "new-id"
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(event)
+	}))
+	defer server.Close()
+
+	provider := NewGraphProvider(staticToken("graph-token"), server.URL)
+	start := time.Date(2026, 3, 2, 11, 0, 0, 0, time.FixedZone("CET", 3600))
+	created, err := provider.CreateEvent("room@example.com", Event{Subject: "Ad hoc", Start: start, End: start.Add(30 * time.Minute)})
+	if err != nil {
+		t.Fatalf("CreateEvent: %v", err)
+	}
+	if created.ID != "new-id" || created.Subject != "Ad hoc" {
+		t.Errorf("created = %+v", created)
+	}
+}
+
+func TestGraphErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":"ErrorItemNotFound"}}`, http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	provider := NewGraphProvider(staticToken("graph-token"), server.URL)
+	err := provider.DeleteEvent("room@example.com", "missing")
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "ErrorItemNotFound") {
+		t.Errorf("DeleteEvent error = %v, want the status and the reply", err)
+	}
+}
@@ -0,0 +1,545 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icsProperty is one content line, e.g. DTSTART;TZID=Europe/Copenhagen:20261017T090000
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icsComponent is a BEGIN/END block such as VCALENDAR, VEVENT or VTIMEZONE
+type icsComponent struct {
+	Name       string
+	Properties []icsProperty
+	Children   []*icsComponent
+}
+
+// get returns the first property called name
+func (c *icsComponent) get(name string) (icsProperty, bool) {
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			return prop, true
+		}
+	}
+	return icsProperty{}, false
+}
+
+// all returns every property called name, e.g. the EXDATE lines of an event
+func (c *icsComponent) all(name string) []icsProperty {
+	var props []icsProperty
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			props = append(props, prop)
+		}
+	}
+	return props
+}
+
+// parseICS reads an iCalendar file and returns its VCALENDAR
+func parseICS(r io.Reader) (*icsComponent, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+
+	// Unfold the lines, a line starting with a space or tab continues the previous one
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	var stack []*icsComponent
+	var root *icsComponent
+	for i, line := range lines {
+		prop, err := parseICSLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch prop.Name {
+		case "BEGIN":
+			component := &icsComponent{Name: strings.ToUpper(prop.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, component)
+			} else if root == nil {
+				root = component
+			}
+			stack = append(stack, component)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) > 0 {
+				component := stack[len(stack)-1]
+				component.Properties = append(component.Properties, prop)
+			}
+		}
+	}
+	if root == nil || root.Name != "VCALENDAR" {
+		return nil, fmt.Errorf("no VCALENDAR found")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// parseICSLine splits a content line into its name, parameters and value
+func parseICSLine(line string) (icsProperty, error) {
+	prop := icsProperty{Params: map[string]string{}}
+	end := strings.IndexAny(line, ";:")
+	if end < 0 {
+		return prop, fmt.Errorf("invalid line %q", line)
+	}
+	prop.Name = strings.ToUpper(line[:end])
+	rest := line[end:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			return prop, fmt.Errorf("invalid parameter in %q", line)
+		}
+		key := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			closing := strings.Index(rest[1:], `"`)
+			if closing < 0 {
+				return prop, fmt.Errorf("unterminated quote in %q", line)
+			}
+			value, rest = rest[1:closing+1], rest[closing+2:]
+		} else {
+			stop := strings.IndexAny(rest, ";:")
+			if stop < 0 {
+				return prop, fmt.Errorf("invalid parameter in %q", line)
+			}
+			value, rest = rest[:stop], rest[stop:]
+		}
+		prop.Params[key] = value
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return prop, fmt.Errorf("missing value in %q", line)
+	}
+	prop.Value = rest[1:]
+	return prop, nil
+}
+
+// unescapeText undoes the escaping of TEXT values such as SUMMARY
+func unescapeText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// zone turns a wall clock time, stored in UTC, into an absolute time
+type zone interface {
+	at(wall time.Time) time.Time
+}
+
+// locationZone is a zone from the Go time zone database, also used for
+// floating times in the server's own zone
+type locationZone struct {
+	location *time.Location
+}
+
+func (z locationZone) at(wall time.Time) time.Time {
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, z.location)
+}
+
+// observance is a STANDARD or DAYLIGHT block of a VTIMEZONE
+type observance struct {
+	start      time.Time
+	rule       *rrule
+	rdates     []time.Time
+	offsetFrom int
+	offsetTo   int
+}
+
+// vtimezone is a time zone defined in the feed itself, so Windows zone names
+// and zones missing from the server's database still work
+type vtimezone struct {
+	id          string
+	observances []observance
+	// years caches the transitions of every year looked up, see transitions
+	years map[int][]transition
+}
+
+// transition is the onset of an observance, in the wall clock time before it
+type transition struct {
+	onset  time.Time
+	offset int
+}
+
+// parseVTimezone reads the STANDARD and DAYLIGHT rules of a VTIMEZONE
+func parseVTimezone(component *icsComponent) (*vtimezone, error) {
+	tzid, _ := component.get("TZID")
+	z := &vtimezone{id: tzid.Value, years: map[int][]transition{}}
+	for _, child := range component.Children {
+		if child.Name != "STANDARD" && child.Name != "DAYLIGHT" {
+			continue
+		}
+		var o observance
+		var err error
+		dtstart, _ := child.get("DTSTART")
+		if o.start, err = time.Parse("20060102T150405", dtstart.Value); err != nil {
+			return nil, fmt.Errorf("zone %s: invalid DTSTART %q", z.id, dtstart.Value)
+		}
+		from, _ := child.get("TZOFFSETFROM")
+		to, _ := child.get("TZOFFSETTO")
+		if o.offsetFrom, err = parseUTCOffset(from.Value); err != nil {
+			return nil, fmt.Errorf("zone %s: %w", z.id, err)
+		}
+		if o.offsetTo, err = parseUTCOffset(to.Value); err != nil {
+			return nil, fmt.Errorf("zone %s: %w", z.id, err)
+		}
+		if rule, ok := child.get("RRULE"); ok {
+			if o.rule, err = parseRRule(rule.Value); err != nil {
+				return nil, fmt.Errorf("zone %s: %w", z.id, err)
+			}
+		}
+		for _, rdate := range child.all("RDATE") {
+			for _, value := range strings.Split(rdate.Value, ",") {
+				if t, err := time.Parse("20060102T150405", value); err == nil {
+					o.rdates = append(o.rdates, t)
+				}
+			}
+		}
+		z.observances = append(z.observances, o)
+	}
+	if len(z.observances) == 0 {
+		return nil, fmt.Errorf("zone %s has no STANDARD or DAYLIGHT rules", z.id)
+	}
+	return z, nil
+}
+
+// parseUTCOffset parses a UTC offset such as +0100 or -023000 into seconds
+func parseUTCOffset(value string) (int, error) {
+	if len(value) != 5 && len(value) != 7 || value[0] != '+' && value[0] != '-' {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	digits := value[1:] + "00"
+	hours, err1 := strconv.Atoi(digits[0:2])
+	minutes, err2 := strconv.Atoi(digits[2:4])
+	seconds, err3 := strconv.Atoi(digits[4:6])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, fmt.Errorf("invalid UTC offset %q", value)
+	}
+	offset := hours*3600 + minutes*60 + seconds
+	if value[0] == '-' {
+		offset = -offset
+	}
+	return offset, nil
+}
+
+// at applies the offset of the last transition before wall
+func (z *vtimezone) at(wall time.Time) time.Time {
+	offset := z.observances[0].offsetFrom
+	for _, t := range z.transitions(wall.Year()) {
+		if t.onset.After(wall) {
+			break
+		}
+		offset = t.offset
+	}
+	return wall.Add(-time.Duration(offset) * time.Second).In(time.FixedZone(z.id, offset))
+}
+
+// transitions returns the transition in effect when year starts, followed by
+// the transitions during the year in order. Every year is worked out once and
+// from the year before, so the rules are never expanded from their DTSTART.
+func (z *vtimezone) transitions(year int) []transition {
+	if cached, ok := z.years[year]; ok {
+		return cached
+	}
+
+	var transitions []transition
+	if year > z.firstYear() {
+		if previous := z.transitions(year - 1); len(previous) > 0 {
+			transitions = append(transitions, previous[len(previous)-1])
+		}
+	}
+
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	var during []transition
+	for _, o := range z.observances {
+		add := func(onset time.Time) {
+			if !onset.Before(start) && onset.Before(end) {
+				during = append(during, transition{onset: onset, offset: o.offsetTo})
+			}
+		}
+		if o.rule != nil {
+			utc := func(t time.Time) time.Time { return t.Add(-time.Duration(o.offsetFrom) * time.Second) }
+			o.rule.expand(o.start, start, utc, func(onset time.Time) bool {
+				add(onset)
+				return onset.Before(end)
+			})
+		} else {
+			add(o.start)
+		}
+		for _, rdate := range o.rdates {
+			add(rdate)
+		}
+	}
+	sort.SliceStable(during, func(i, j int) bool { return during[i].onset.Before(during[j].onset) })
+
+	transitions = append(transitions, during...)
+	z.years[year] = transitions
+	return transitions
+}
+
+// firstYear is the year of the earliest observance, no transition is before it
+func (z *vtimezone) firstYear() int {
+	first := z.observances[0].start.Year()
+	for _, o := range z.observances {
+		first = min(first, o.start.Year())
+		for _, rdate := range o.rdates {
+			first = min(first, rdate.Year())
+		}
+	}
+	return first
+}
+
+// icsTime is a parsed DATE or DATE-TIME value
+type icsTime struct {
+	wall   time.Time
+	zone   zone
+	tzid   string
+	allDay bool
+}
+
+func (t icsTime) abs() time.Time {
+	return t.zone.at(t.wall)
+}
+
+// icsZones resolves the TZID of times to the VTIMEZONEs of the feed, then
+// to the Go time zone database
+type icsZones map[string]zone
+
+func newICSZones(calendar *icsComponent) icsZones {
+	zones := icsZones{}
+	for _, child := range calendar.Children {
+		if child.Name != "VTIMEZONE" {
+			continue
+		}
+		z, err := parseVTimezone(child)
+		if err != nil {
+			log.Printf("Ignoring time zone: %v", err)
+			continue
+		}
+		zones[z.id] = z
+	}
+	return zones
+}
+
+func (zones icsZones) lookup(tzid string) zone {
+	if z, ok := zones[tzid]; ok {
+		return z
+	}
+	location, err := time.LoadLocation(tzid)
+	if err != nil {
+		log.Printf("Unknown time zone %q, using the server's zone", tzid)
+		location = time.Local
+	}
+	zones[tzid] = locationZone{location}
+	return zones[tzid]
+}
+
+// parseTimes parses the comma separated DATE or DATE-TIME values of prop,
+// times without a zone are in the server's zone
+func (zones icsZones) parseTimes(prop icsProperty) ([]icsTime, error) {
+	var times []icsTime
+	for _, value := range strings.Split(prop.Value, ",") {
+		var t icsTime
+		var err error
+		switch {
+		case prop.Params["VALUE"] == "DATE" || len(value) == 8:
+			t.wall, err = time.Parse("20060102", value)
+			t.allDay = true
+			t.zone = locationZone{time.Local}
+		case strings.HasSuffix(value, "Z"):
+			t.wall, err = time.Parse("20060102T150405Z", value)
+			t.tzid = "UTC"
+			t.zone = locationZone{time.UTC}
+		default:
+			t.wall, err = time.Parse("20060102T150405", value)
+			t.tzid = prop.Params["TZID"]
+			t.zone = locationZone{time.Local}
+			if t.tzid != "" {
+				t.zone = zones.lookup(t.tzid)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", prop.Name, value)
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// parseTime parses a property holding a single DATE or DATE-TIME
+func (zones icsZones) parseTime(prop icsProperty) (icsTime, error) {
+	times, err := zones.parseTimes(prop)
+	if err != nil {
+		return icsTime{}, err
+	}
+	return times[0], nil
+}
+
+// parseDuration parses a DURATION such as PT30M, P1DT2H or -P1W
+func parseDuration(value string) (time.Duration, error) {
+	sign := time.Duration(1)
+	rest := value
+	if rest, _ = strings.CutPrefix(rest, "+"); strings.HasPrefix(rest, "-") {
+		sign, rest = -1, rest[1:]
+	}
+	rest, ok := strings.CutPrefix(rest, "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	var total time.Duration
+	inTime := false
+	number := ""
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == 'T':
+			inTime = true
+		case c >= '0' && c <= '9':
+			number += string(c)
+		default:
+			unit, ok := units[c]
+			if !ok || number == "" || c == 'M' && !inTime {
+				return 0, fmt.Errorf("invalid duration %q", value)
+			}
+			n, _ := strconv.Atoi(number)
+			total += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	if number != "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return sign * total, nil
+}
+
+// escapeText escapes a TEXT value such as SUMMARY
+func escapeText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// formatICS writes event as a calendar object with one VEVENT, in UTC
+func formatICS(uid string, event Event) string {
+	const utc = "20060102T150405Z"
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//MeetingRoomSoftware//Room panel//EN",
+		"BEGIN:VEVENT",
+		"UID:" + uid,
+		"DTSTAMP:" + time.Now().UTC().Format(utc),
+		"DTSTART:" + event.Start.UTC().Format(utc),
+		"DTEND:" + event.End.UTC().Format(utc),
+		"SUMMARY:" + escapeText(event.Subject),
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// errRecurringEvent is returned when changing a calendar object with a recurring
+// event, its events all share the object so one occurrence can not be changed alone
+var errRecurringEvent = errors.New("recurring events can not be changed from the room")
+
+// singleEvent returns the only VEVENT of calendar, or an error if it is recurring
+func singleEvent(calendar *icsComponent) (*icsComponent, error) {
+	var events []*icsComponent
+	for _, child := range calendar.Children {
+		if child.Name == "VEVENT" {
+			events = append(events, child)
+		}
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("no VEVENT found")
+	}
+	if len(events) > 1 {
+		return nil, errRecurringEvent
+	}
+	for _, name := range []string{"RRULE", "RDATE", "RECURRENCE-ID"} {
+		if _, ok := events[0].get(name); ok {
+			return nil, errRecurringEvent
+		}
+	}
+	return events[0], nil
+}
+
+// updateICS replaces the DTSTART, DTEND and SUMMARY of the only event in a
+// calendar object with those of event. Every other line, e.g. the attendees,
+// location, alarms and time zones, is kept as it was written.
+func updateICS(data string, event Event) (string, error) {
+	calendar, err := parseICS(strings.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if _, err := singleEvent(calendar); err != nil {
+		return "", err
+	}
+
+	// Group the folded lines with the line they continue
+	var lines [][]string
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] = append(lines[len(lines)-1], line)
+		} else if line != "" {
+			lines = append(lines, []string{line})
+		}
+	}
+
+	const utc = "20060102T150405Z"
+	var out []string
+	var stack []string
+	for _, folded := range lines {
+		unfolded := folded[0]
+		for _, continued := range folded[1:] {
+			unfolded += continued[1:]
+		}
+		prop, err := parseICSLine(unfolded)
+		if err != nil {
+			return "", err
+		}
+		inEvent := len(stack) > 0 && stack[len(stack)-1] == "VEVENT"
+		switch {
+		case prop.Name == "BEGIN":
+			stack = append(stack, strings.ToUpper(prop.Value))
+		case prop.Name == "END":
+			if inEvent {
+				out = append(out,
+					"DTSTART:"+event.Start.UTC().Format(utc),
+					"DTEND:"+event.End.UTC().Format(utc),
+					"SUMMARY:"+escapeText(event.Subject),
+				)
+			}
+			stack = stack[:len(stack)-1]
+		case inEvent && (prop.Name == "DTSTART" || prop.Name == "DTEND" || prop.Name == "DURATION" || prop.Name == "SUMMARY"):
+			// Replaced at the END of the event, DURATION gives way to DTEND
+			continue
+		}
+		out = append(out, folded...)
+	}
+	return strings.Join(out, "\r\n") + "\r\n", nil
+}
@@ -0,0 +1,252 @@
+package calendar
+
+import (
+	"backend/pkg/serialhandler"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ICSProvider reads the room calendar from an iCalendar feed, an .ics URL or
+// file published by a booking system. The feed is read-only.
+type ICSProvider struct {
+	URL    string
+	Path   string
+	Client *http.Client
+}
+
+// NewICSProvider returns a provider for the feed at url, or in the file at path
+func NewICSProvider(url, path string) *ICSProvider {
+	return &ICSProvider{URL: url, Path: path, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+func newICSProviderFromConfig(config serialhandler.CalendarConfig) (CalendarProvider, error) {
+	if config.URL == "" && config.Path == "" {
+		return nil, fmt.Errorf("the ics calendar provider needs a url or a path")
+	}
+	return NewICSProvider(config.URL, config.Path), nil
+}
+
+// ListEvents reads the feed and returns the events and occurrences of
+// recurring events overlapping start to end. The room is set by the feed.
+func (p *ICSProvider) ListEvents(room string, start, end time.Time) ([]Event, error) {
+	feed, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+	defer feed.Close()
+
+	calendar, err := parseICS(feed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse calendar: %w", err)
+	}
+	return icsEvents(calendar, start, end), nil
+}
+
+// CreateEvent is not possible, the feed is published by another system
+func (p *ICSProvider) CreateEvent(room string, event Event) (*Event, error) {
+	return nil, ErrReadOnly
+}
+
+// UpdateEvent is not possible, the feed is published by another system
+func (p *ICSProvider) UpdateEvent(room string, event Event) (*Event, error) {
+	return nil, ErrReadOnly
+}
+
+// DeleteEvent is not possible, the feed is published by another system
+func (p *ICSProvider) DeleteEvent(room string, id string) error {
+	return ErrReadOnly
+}
+
+// open fetches the feed, webcal:// URLs are fetched over https
+func (p *ICSProvider) open() (io.ReadCloser, error) {
+	if p.Path != "" {
+		file, err := os.Open(p.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open calendar file: %w", err)
+		}
+		return file, nil
+	}
+
+	feedURL := p.URL
+	if rest, ok := strings.CutPrefix(feedURL, "webcal://"); ok {
+		feedURL = "https://" + rest
+	}
+	resp, err := p.Client.Get(feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch calendar: %s, response: %s", resp.Status, string(body))
+	}
+	return resp.Body, nil
+}
+
+// icsEvent is a VEVENT with its times parsed
+type icsEvent struct {
+	uid        string
+	subject    string
+	start      icsTime
+	duration   time.Duration
+	rule       *rrule
+	rdates     []icsTime
+	exdates    map[int64]bool
+	cancelled  bool
+	recurrence *icsTime
+}
+
+// icsEvents expands the VEVENTs of calendar into the events overlapping start to end
+func icsEvents(calendar *icsComponent, start, end time.Time) []Event {
+	zones := newICSZones(calendar)
+
+	var masters []*icsEvent
+	// overridden holds the occurrences moved or cancelled by a RECURRENCE-ID, per UID
+	overridden := map[string]map[int64]bool{}
+	var events []Event
+	for _, child := range calendar.Children {
+		if child.Name != "VEVENT" {
+			continue
+		}
+		event, err := parseICSEvent(child, zones)
+		if err != nil {
+			log.Printf("Ignoring calendar event: %v", err)
+			continue
+		}
+		if event.recurrence != nil {
+			if overridden[event.uid] == nil {
+				overridden[event.uid] = map[int64]bool{}
+			}
+			overridden[event.uid][event.recurrence.abs().Unix()] = true
+		}
+		if event.cancelled {
+			continue
+		}
+		if event.rule != nil || len(event.rdates) > 0 {
+			masters = append(masters, event)
+			continue
+		}
+		events = event.appendIfOverlapping(events, event.start, start, end)
+	}
+
+	for _, event := range masters {
+		skip := func(occurrence icsTime) bool {
+			unix := occurrence.abs().Unix()
+			return event.exdates[unix] || overridden[event.uid][unix]
+		}
+		if event.rule != nil {
+			// Wall clock times are at most a day off the absolute ones, the
+			// occurrences that end before start are not expanded
+			from := start.UTC().Add(-event.duration - 24*time.Hour)
+			event.rule.expand(event.start.wall, from, event.start.zone.at, func(wall time.Time) bool {
+				occurrence := event.start
+				occurrence.wall = wall
+				if !occurrence.abs().Before(end) {
+					return false
+				}
+				if !skip(occurrence) {
+					events = event.appendIfOverlapping(events, occurrence, start, end)
+				}
+				return true
+			})
+		} else if !skip(event.start) {
+			events = event.appendIfOverlapping(events, event.start, start, end)
+		}
+		for _, rdate := range event.rdates {
+			if !skip(rdate) {
+				events = event.appendIfOverlapping(events, rdate, start, end)
+			}
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
+
+// appendIfOverlapping adds the occurrence of e starting at occurrence if it overlaps start to end
+func (e *icsEvent) appendIfOverlapping(events []Event, occurrence icsTime, start, end time.Time) []Event {
+	// The duration is kept in wall clock time, a 9-10 meeting stays 9-10 across DST changes
+	eventStart := occurrence.abs()
+	eventEnd := occurrence.zone.at(occurrence.wall.Add(e.duration))
+	if !eventStart.Before(end) || !eventEnd.After(start) {
+		return events
+	}
+	return append(events, Event{ID: e.uid, Subject: e.subject, Start: eventStart, End: eventEnd})
+}
+
+// parseICSEvent reads the times, recurrence and exceptions of a VEVENT
+func parseICSEvent(component *icsComponent, zones icsZones) (*icsEvent, error) {
+	uid, _ := component.get("UID")
+	summary, _ := component.get("SUMMARY")
+	event := &icsEvent{uid: uid.Value, subject: unescapeText(summary.Value), exdates: map[int64]bool{}}
+
+	status, _ := component.get("STATUS")
+	event.cancelled = strings.EqualFold(status.Value, "CANCELLED")
+
+	dtstart, ok := component.get("DTSTART")
+	if !ok {
+		return nil, fmt.Errorf("event %q has no DTSTART", event.uid)
+	}
+	var err error
+	if event.start, err = zones.parseTime(dtstart); err != nil {
+		return nil, fmt.Errorf("event %q: %w", event.uid, err)
+	}
+
+	if dtend, ok := component.get("DTEND"); ok {
+		end, err := zones.parseTime(dtend)
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %w", event.uid, err)
+		}
+		if end.tzid == event.start.tzid {
+			event.duration = end.wall.Sub(event.start.wall)
+		} else {
+			event.duration = end.abs().Sub(event.start.abs())
+		}
+	} else if duration, ok := component.get("DURATION"); ok {
+		if event.duration, err = parseDuration(duration.Value); err != nil {
+			return nil, fmt.Errorf("event %q: %w", event.uid, err)
+		}
+	} else if event.start.allDay {
+		event.duration = 24 * time.Hour
+	}
+
+	if rule, ok := component.get("RRULE"); ok {
+		if event.rule, err = parseRRule(rule.Value); err != nil {
+			return nil, fmt.Errorf("event %q: %w", event.uid, err)
+		}
+	}
+	for _, prop := range component.all("RDATE") {
+		if prop.Params["VALUE"] == "PERIOD" {
+			log.Printf("Event %q: ignoring RDATE periods", event.uid)
+			continue
+		}
+		times, err := zones.parseTimes(prop)
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %w", event.uid, err)
+		}
+		event.rdates = append(event.rdates, times...)
+	}
+	for _, prop := range component.all("EXDATE") {
+		times, err := zones.parseTimes(prop)
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %w", event.uid, err)
+		}
+		for _, t := range times {
+			event.exdates[t.abs().Unix()] = true
+		}
+	}
+	if prop, ok := component.get("RECURRENCE-ID"); ok {
+		recurrence, err := zones.parseTime(prop)
+		if err != nil {
+			return nil, fmt.Errorf("event %q: %w", event.uid, err)
+		}
+		event.recurrence = &recurrence
+	}
+	return event, nil
+}
@@ -0,0 +1,177 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// outlookZone is the VTIMEZONE Outlook and Exchange put in their feeds,
+// with rules starting in 1601
+const outlookZone = `BEGIN:VTIMEZONE
+TZID:W. Europe Standard Time
+BEGIN:STANDARD
+DTSTART:16010101T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=10
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:16010101T020000
+TZOFFSETFROM:+0100
+TZOFFSETTO:+0200
+RRULE:FREQ=YEARLY;INTERVAL=1;BYDAY=-1SU;BYMONTH=3
+END:DAYLIGHT
+END:VTIMEZONE
+`
+
+// feedEvents parses a feed made of the given components and lists the events from start to end
+func feedEvents(t *testing.T, components string, start, end time.Time) []Event {
+	t.Helper()
+	feed := "BEGIN:VCALENDAR\nVERSION:2.0\n" + components + "END:VCALENDAR\n"
+	path := filepath.Join(t.TempDir(), "room.ics")
+	if err := os.WriteFile(path, []byte(strings.ReplaceAll(feed, "\n", "\r\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	events, err := NewICSProvider("", path).ListEvents("room", start, end)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	return events
+}
+
+// utcTimes formats the start and end of events in UTC
+func utcTimes(events []Event) []string {
+	var times []string
+	for _, event := range events {
+		times = append(times, event.Start.UTC().Format("01-02 15:04")+"-"+event.End.UTC().Format("15:04"))
+	}
+	return times
+}
+
+func TestVTimezoneOffsets(t *testing.T) {
+	calendar, err := parseICS(strings.NewReader(strings.ReplaceAll("BEGIN:VCALENDAR\n"+outlookZone+"END:VCALENDAR\n", "\n", "\r\n")))
+	if err != nil {
+		t.Fatalf("parseICS: %v", err)
+	}
+	zone := newICSZones(calendar).lookup("W. Europe Standard Time")
+
+	tests := []struct {
+		wall time.Time
+		want string
+	}{
+		{time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC), "2026-01-15 11:00"},
+		{time.Date(2026, 3, 29, 1, 30, 0, 0, time.UTC), "2026-03-29 00:30"},
+		{time.Date(2026, 3, 29, 3, 30, 0, 0, time.UTC), "2026-03-29 01:30"},
+		{time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC), "2026-07-01 10:00"},
+		{time.Date(2026, 10, 25, 3, 30, 0, 0, time.UTC), "2026-10-25 02:30"},
+		{time.Date(2026, 12, 31, 23, 0, 0, 0, time.UTC), "2026-12-31 22:00"},
+		{time.Date(1999, 7, 1, 12, 0, 0, 0, time.UTC), "1999-07-01 10:00"},
+	}
+	for _, test := range tests {
+		if got := zone.at(test.wall).UTC().Format("2006-01-02 15:04"); got != test.want {
+			t.Errorf("at(%s) = %s UTC, want %s", test.wall.Format("2006-01-02 15:04"), got, test.want)
+		}
+	}
+}
+
+func TestICSRecurringEventKeepsWallClock(t *testing.T) {
+	feed := outlookZone + `BEGIN:VEVENT
+UID:standup
+SUMMARY:Standup
+DTSTART;TZID=W. Europe Standard Time:20240101T090000
+DTEND;TZID=W. Europe Standard Time:20240101T091500
+RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR
+EXDATE;TZID=W. Europe Standard Time:20260325T090000
+END:VEVENT
+BEGIN:VEVENT
+UID:standup
+RECURRENCE-ID;TZID=W. Europe Standard Time:20260330T090000
+SUMMARY:Standup (moved)
+DTSTART;TZID=W. Europe Standard Time:20260330T100000
+DTEND;TZID=W. Europe Standard Time:20260330T101500
+END:VEVENT
+`
+	start := time.Date(2026, 3, 23, 0, 0, 0, 0, time.UTC)
+	events := feedEvents(t, feed, start, start.AddDate(0, 0, 10))
+
+	// Winter time until the 29th, the 25th is excluded and the 30th moved
+	want := []string{"03-23 08:00-08:15", "03-27 08:00-08:15", "03-30 08:00-08:15", "04-01 07:00-07:15"}
+	if got := utcTimes(events); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("occurrences = %q, want %q", got, want)
+	}
+	if events[2].Subject != "Standup (moved)" {
+		t.Errorf("subject on the 30th = %q, want the override", events[2].Subject)
+	}
+}
+
+func TestICSOccurrenceOverlappingStart(t *testing.T) {
+	feed := `BEGIN:VEVENT
+UID:workshop
+SUMMARY:Workshop
+DTSTART:20260101T080000Z
+DTEND:20260101T120000Z
+RRULE:FREQ=DAILY;COUNT=400
+END:VEVENT
+`
+	start := time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)
+	events := feedEvents(t, feed, start, start.Add(time.Hour))
+	if got := utcTimes(events); len(got) != 1 || got[0] != "05-04 08:00-12:00" {
+		t.Errorf("occurrences = %q, want the one in progress", got)
+	}
+}
+
+func TestICSOldSeriesAreCheap(t *testing.T) {
+	feed := outlookZone + `BEGIN:VEVENT
+UID:daily
+SUMMARY:Daily
+DTSTART;TZID=W. Europe Standard Time:20040105T083000
+DTEND;TZID=W. Europe Standard Time:20040105T084500
+RRULE:FREQ=DAILY
+END:VEVENT
+BEGIN:VEVENT
+UID:mwf
+SUMMARY:MWF
+DTSTART;TZID=W. Europe Standard Time:20240101T140000
+DTEND;TZID=W. Europe Standard Time:20240101T150000
+RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR
+END:VEVENT
+`
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	began := time.Now()
+	events := feedEvents(t, feed, start, start.AddDate(0, 0, 7))
+	if took := time.Since(began); took > time.Second {
+		t.Errorf("ListEvents took %s", took)
+	}
+
+	// 7 daily occurrences (past 21.9 years since DTSTART) and 3 on MWF, in summer time
+	if len(events) != 10 {
+		t.Fatalf("got %d events, want 10: %q", len(events), utcTimes(events))
+	}
+	if got := utcTimes(events)[0]; got != "06-01 06:30-06:45" {
+		t.Errorf("first occurrence = %s, want 06-01 06:30-06:45", got)
+	}
+}
+
+func TestICSCancelledAndAllDayEvents(t *testing.T) {
+	feed := `BEGIN:VEVENT
+UID:cancelled
+SUMMARY:Cancelled
+STATUS:CANCELLED
+DTSTART:20260302T090000Z
+DTEND:20260302T100000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:holiday
+SUMMARY:Holiday
+DTSTART;VALUE=DATE:20260302
+END:VEVENT
+`
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.Local)
+	events := feedEvents(t, feed, start, start.Add(24*time.Hour))
+	if len(events) != 1 || events[0].Subject != "Holiday" || events[0].End.Sub(events[0].Start) != 24*time.Hour {
+		t.Errorf("events = %+v, want only the all-day holiday", events)
+	}
+}
@@ -0,0 +1,54 @@
+package calendar
+
+import (
+	"backend/pkg/serialhandler"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrReadOnly is returned when booking in a calendar the provider can only read
+var ErrReadOnly = errors.New("calendar is read-only")
+
+// Event is a meeting in the room calendar
+type Event struct {
+	ID      string    `json:"id"`
+	Subject string    `json:"subject"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+}
+
+// CalendarProvider reads and books meetings in a room calendar. room is the
+// calendar's identifier in the provider, the room mailbox for Graph.
+type CalendarProvider interface {
+	// ListEvents returns the events overlapping start to end, recurring events expanded
+	ListEvents(room string, start, end time.Time) ([]Event, error)
+	// CreateEvent books event and returns it as stored, with its ID
+	CreateEvent(room string, event Event) (*Event, error)
+	// UpdateEvent replaces the subject and times of the event with event.ID
+	UpdateEvent(room string, event Event) (*Event, error)
+	DeleteEvent(room string, id string) error
+}
+
+// providers maps the "provider" key of the calendar block to its constructor
+var providers = map[string]func(config serialhandler.CalendarConfig) (CalendarProvider, error){
+	"graph":  newGraphProviderFromEnv,
+	"ics":    newICSProviderFromConfig,
+	"caldav": newCalDAVProviderFromEnv,
+	"google": newGoogleProviderFromConfig,
+}
+
+// NewProvider returns the provider selected by config.Provider, Microsoft Graph by default
+func NewProvider(config serialhandler.CalendarConfig) (CalendarProvider, error) {
+	name := config.Provider
+	if name == "" {
+		name = "graph"
+	}
+	newProvider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown calendar provider %q", name)
+	}
+	log.Printf("Using calendar provider: %s", name)
+	return newProvider(config)
+}
@@ -0,0 +1,362 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeriods stops the expansion of rules that never produce an occurrence,
+// e.g. BYMONTHDAY=30 with BYMONTH=2
+const maxPeriods = 100000
+
+// weekdayNames are the two letter weekdays of RRULE
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// byDay is one BYDAY entry, e.g. -1SU for the last Sunday
+type byDay struct {
+	ord int
+	day time.Weekday
+}
+
+// rrule is a parsed RRULE. Occurrences are worked out on wall clock times
+// (stored in UTC) so a meeting keeps its time of day across DST changes.
+type rrule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	untilDate  bool
+	byDay      []byDay
+	byMonthDay []int
+	byMonth    []int
+	bySetPos   []int
+	wkst       time.Weekday
+}
+
+// parseRRule parses the value of an RRULE, e.g. FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20261231T235959Z
+func parseRRule(value string) (*rrule, error) {
+	r := &rrule{interval: 1, wkst: time.Monday}
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.freq = strings.ToUpper(val)
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(val)
+			if err == nil && r.interval < 1 {
+				err = fmt.Errorf("interval must be at least 1")
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(val)
+		case "UNTIL":
+			if len(val) == 8 {
+				r.until, err = time.Parse("20060102", val)
+				r.untilDate = true
+			} else {
+				r.until, err = time.Parse("20060102T150405Z", val)
+				if err != nil {
+					// A floating UNTIL, compared with the wall clock like a date
+					r.until, err = time.Parse("20060102T150405", val)
+					r.untilDate = true
+				}
+			}
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				day, ok := weekdayNames[strings.ToUpper(item[max(len(item)-2, 0):])]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY %q", item)
+				}
+				ord := 0
+				if prefix := item[:len(item)-2]; prefix != "" {
+					if ord, err = strconv.Atoi(prefix); err != nil {
+						return nil, fmt.Errorf("invalid BYDAY %q", item)
+					}
+				}
+				r.byDay = append(r.byDay, byDay{ord: ord, day: day})
+			}
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseInts(val)
+		case "BYMONTH":
+			r.byMonth, err = parseInts(val)
+		case "BYSETPOS":
+			r.bySetPos, err = parseInts(val)
+		case "WKST":
+			day, ok := weekdayNames[strings.ToUpper(val)]
+			if !ok {
+				err = fmt.Errorf("invalid WKST %q", val)
+			}
+			r.wkst = day
+		default:
+			return nil, fmt.Errorf("unsupported rule part %s", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("unsupported FREQ %q", r.freq)
+	}
+	return r, nil
+}
+
+func parseInts(value string) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(item)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+// expand calls yield with the wall clock start of every occurrence from
+// from on, in order, until yield returns false or the rule ends. DTSTART is
+// the first occurrence. abs turns a wall clock time into the absolute time
+// UNTIL is compared with. Without a COUNT the periods before from are not
+// expanded at all, so old series cost no more than new ones.
+func (r *rrule) expand(dtstart, from time.Time, abs func(time.Time) time.Time, yield func(time.Time) bool) {
+	n := 0
+	emit := func(wall time.Time) bool {
+		n++
+		if wall.Before(from) {
+			// Only counted, a rule has a COUNT or an UNTIL but not both
+			return r.count == 0 || n < r.count
+		}
+		if r.untilDate && wall.After(r.until) || !r.untilDate && !r.until.IsZero() && abs(wall).After(r.until) {
+			return false
+		}
+		return yield(wall) && (r.count == 0 || n < r.count)
+	}
+	if !emit(dtstart) {
+		return
+	}
+
+	first := 0
+	if r.count == 0 {
+		first = r.firstPeriod(dtstart, from)
+	}
+	for period := first; period < first+maxPeriods; period++ {
+		candidates := r.period(dtstart, period)
+		if candidates == nil {
+			return
+		}
+		for _, wall := range candidates {
+			if !wall.After(dtstart) {
+				continue
+			}
+			if !emit(wall) {
+				return
+			}
+		}
+	}
+}
+
+// firstPeriod returns a period at or before the first one holding
+// occurrences from from on
+func (r *rrule) firstPeriod(dtstart, from time.Time) int {
+	if !from.After(dtstart) {
+		return 0
+	}
+	var elapsed int
+	switch r.freq {
+	case "DAILY":
+		elapsed = int((from.Unix() - dtstart.Unix()) / (24 * 3600))
+	case "WEEKLY":
+		elapsed = int((from.Unix() - dtstart.Unix()) / (7 * 24 * 3600))
+	case "MONTHLY":
+		elapsed = (from.Year()-dtstart.Year())*12 + int(from.Month()-dtstart.Month())
+	case "YEARLY":
+		elapsed = from.Year() - dtstart.Year()
+	}
+	// One period back for the days of a week before the weekday of DTSTART
+	return max(elapsed/r.interval-1, 0)
+}
+
+// period returns the sorted occurrences of the period-th day, week, month or
+// year after DTSTART, nil past the end of the calendar
+func (r *rrule) period(dtstart time.Time, period int) []time.Time {
+	y, m, d := dtstart.Date()
+	clock := dtstart.Sub(time.Date(y, m, d, 0, 0, 0, 0, time.UTC))
+	step := period * r.interval
+
+	// first is the first day of the period
+	var first time.Time
+	var days []time.Time
+	switch r.freq {
+	case "DAILY":
+		first = time.Date(y, m, d+step, 0, 0, 0, 0, time.UTC)
+		if r.matchMonth(first) && r.matchMonthDay(first) && r.matchWeekday(first) {
+			days = append(days, first)
+		}
+	case "WEEKLY":
+		first = time.Date(y, m, d-int(dtstart.Weekday()-r.wkst+7)%7+7*step, 0, 0, 0, 0, time.UTC)
+		weekdays := []time.Weekday{dtstart.Weekday()}
+		if len(r.byDay) > 0 {
+			weekdays = nil
+			for _, bd := range r.byDay {
+				weekdays = append(weekdays, bd.day)
+			}
+		}
+		for _, weekday := range weekdays {
+			day := first.AddDate(0, 0, int(weekday-r.wkst+7)%7)
+			if r.matchMonth(day) {
+				days = append(days, day)
+			}
+		}
+	case "MONTHLY":
+		first = time.Date(y, m+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		if r.matchMonth(first) {
+			days = r.monthDays(first, d)
+		}
+	case "YEARLY":
+		year := y + step
+		first = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		switch {
+		case len(r.byMonth) > 0:
+			for _, month := range r.byMonth {
+				days = append(days, r.monthDays(time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), d)...)
+			}
+		case len(r.byMonthDay) > 0:
+			days = r.monthDays(time.Date(year, m, 1, 0, 0, 0, 0, time.UTC), d)
+		case len(r.byDay) > 0:
+			days = weekdaysIn(first, first.AddDate(1, 0, -1), r.byDay)
+		default:
+			if day := time.Date(year, m, d, 0, 0, 0, 0, time.UTC); day.Day() == d {
+				days = append(days, day)
+			}
+		}
+	}
+	if first.Year() > 9999 {
+		return nil
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	days = r.setPos(days)
+	occurrences := make([]time.Time, 0, len(days))
+	for i, day := range days {
+		if i > 0 && day.Equal(days[i-1]) {
+			continue
+		}
+		occurrences = append(occurrences, day.Add(clock))
+	}
+	return occurrences
+}
+
+// monthDays returns the days of the month picked by BYMONTHDAY and BYDAY,
+// the day of DTSTART without either
+func (r *rrule) monthDays(month time.Time, dtstartDay int) []time.Time {
+	last := month.AddDate(0, 1, -1)
+	var days []time.Time
+	switch {
+	case len(r.byDay) > 0:
+		for _, day := range weekdaysIn(month, last, r.byDay) {
+			if r.matchMonthDay(day) {
+				days = append(days, day)
+			}
+		}
+	case len(r.byMonthDay) > 0:
+		for _, n := range r.byMonthDay {
+			if n < 0 {
+				n += last.Day() + 1
+			}
+			if n >= 1 && n <= last.Day() {
+				days = append(days, month.AddDate(0, 0, n-1))
+			}
+		}
+	default:
+		if dtstartDay <= last.Day() {
+			days = append(days, month.AddDate(0, 0, dtstartDay-1))
+		}
+	}
+	return days
+}
+
+// weekdaysIn returns the days from first to last matching byDay, an ordinal
+// picks the n-th (or from the end, n-th last) of them
+func weekdaysIn(first, last time.Time, byDays []byDay) []time.Time {
+	var days []time.Time
+	for _, bd := range byDays {
+		var matches []time.Time
+		for day := first.AddDate(0, 0, int(bd.day-first.Weekday()+7)%7); !day.After(last); day = day.AddDate(0, 0, 7) {
+			matches = append(matches, day)
+		}
+		switch {
+		case bd.ord == 0:
+			days = append(days, matches...)
+		case bd.ord > 0 && bd.ord <= len(matches):
+			days = append(days, matches[bd.ord-1])
+		case bd.ord < 0 && -bd.ord <= len(matches):
+			days = append(days, matches[len(matches)+bd.ord])
+		}
+	}
+	return days
+}
+
+// setPos keeps the BYSETPOS positions of the sorted days of a period
+func (r *rrule) setPos(days []time.Time) []time.Time {
+	if len(r.bySetPos) == 0 {
+		return days
+	}
+	var picked []time.Time
+	for _, pos := range r.bySetPos {
+		if pos < 0 {
+			pos += len(days) + 1
+		}
+		if pos >= 1 && pos <= len(days) {
+			picked = append(picked, days[pos-1])
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool { return picked[i].Before(picked[j]) })
+	return picked
+}
+
+func (r *rrule) matchMonth(day time.Time) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, month := range r.byMonth {
+		if day.Month() == time.Month(month) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rrule) matchMonthDay(day time.Time) bool {
+	if len(r.byMonthDay) == 0 {
+		return true
+	}
+	last := day.AddDate(0, 1, -day.Day()).Day()
+	for _, n := range r.byMonthDay {
+		if n == day.Day() || n < 0 && n+last+1 == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rrule) matchWeekday(day time.Time) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	for _, bd := range r.byDay {
+		if bd.day == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// expandRule returns the first n occurrences of rule from from on, as wall clock times
+func expandRule(t *testing.T, rule string, dtstart, from time.Time, n int) []string {
+	t.Helper()
+	r, err := parseRRule(rule)
+	if err != nil {
+		t.Fatalf("parseRRule(%q): %v", rule, err)
+	}
+	var occurrences []string
+	r.expand(dtstart, from, func(wall time.Time) time.Time { return wall }, func(wall time.Time) bool {
+		occurrences = append(occurrences, wall.Format("2006-01-02 15:04 Mon"))
+		return len(occurrences) < n
+	})
+	return occurrences
+}
+
+func TestRRuleExpand(t *testing.T) {
+	dtstart := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC) // a Monday
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"FREQ=DAILY;COUNT=3", []string{"2026-01-05 09:00 Mon", "2026-01-06 09:00 Tue", "2026-01-07 09:00 Wed"}},
+		{"FREQ=DAILY;INTERVAL=2;UNTIL=20260109T090000Z", []string{"2026-01-05 09:00 Mon", "2026-01-07 09:00 Wed", "2026-01-09 09:00 Fri"}},
+		{"FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=4", []string{"2026-01-05 09:00 Mon", "2026-01-07 09:00 Wed", "2026-01-09 09:00 Fri", "2026-01-12 09:00 Mon"}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU;COUNT=3", []string{"2026-01-05 09:00 Mon", "2026-01-06 09:00 Tue", "2026-01-20 09:00 Tue"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", []string{"2026-01-05 09:00 Mon", "2026-01-30 09:00 Fri", "2026-02-27 09:00 Fri"}},
+		{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=1;COUNT=3", []string{"2026-01-05 09:00 Mon", "2026-02-02 09:00 Mon", "2026-03-02 09:00 Mon"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3", []string{"2026-01-05 09:00 Mon", "2026-01-31 09:00 Sat", "2026-03-31 09:00 Tue"}},
+		{"FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU;COUNT=3", []string{"2026-01-05 09:00 Mon", "2026-03-29 09:00 Sun", "2027-03-28 09:00 Sun"}},
+	}
+	for _, test := range tests {
+		if got := expandRule(t, test.rule, dtstart, dtstart, len(test.want)+1); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.rule, got, test.want)
+		}
+	}
+}
+
+func TestRRuleExpandFrom(t *testing.T) {
+	dtstart := time.Date(2004, 1, 5, 9, 0, 0, 0, time.UTC)
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	// Skipped periods leave the occurrences from from on unchanged
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"FREQ=DAILY", []string{"2026-03-02 09:00 Mon", "2026-03-03 09:00 Tue"}},
+		{"FREQ=WEEKLY;BYDAY=MO,WE,FR", []string{"2026-03-02 09:00 Mon", "2026-03-04 09:00 Wed"}},
+		{"FREQ=WEEKLY;INTERVAL=3;BYDAY=SU,WE", []string{"2026-03-18 09:00 Wed", "2026-03-22 09:00 Sun"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15", []string{"2026-03-15 09:00 Sun", "2026-04-01 09:00 Wed"}},
+		{"FREQ=YEARLY", []string{"2027-01-05 09:00 Tue", "2028-01-05 09:00 Wed"}},
+	}
+	for _, test := range tests {
+		got := expandRule(t, test.rule, dtstart, from, len(test.want))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.rule, got, test.want)
+		}
+		// The same occurrences expanded from DTSTART
+		all := expandRule(t, test.rule, dtstart, dtstart, 100000)
+		for i, occurrence := range all {
+			if occurrence >= test.want[0] {
+				if !reflect.DeepEqual(all[i:i+len(test.want)], test.want) {
+					t.Errorf("%s: expanding from DTSTART gives %q", test.rule, all[i:i+len(test.want)])
+				}
+				break
+			}
+		}
+	}
+
+	// A COUNT still counts the occurrences before from
+	if got := expandRule(t, "FREQ=DAILY;COUNT=3", dtstart, dtstart.AddDate(0, 0, 1), 10); len(got) != 2 {
+		t.Errorf("COUNT=3 from the second day: got %q, want 2 occurrences", got)
+	}
+}
+
+func TestRRuleDailyReachesFarYears(t *testing.T) {
+	// A daily step used to be compared with the year 9999 as if it were years
+	dtstart := time.Date(2004, 1, 5, 9, 0, 0, 0, time.UTC)
+	from := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	if got := expandRule(t, "FREQ=DAILY", dtstart, from, 1); len(got) != 1 || got[0] != "2030-06-01 09:00 Sat" {
+		t.Errorf("daily series in 2030 = %q", got)
+	}
+
+	// The calendar still ends with 9999
+	end := time.Date(9999, 12, 30, 0, 0, 0, 0, time.UTC)
+	if got := expandRule(t, "FREQ=DAILY", dtstart, end, 5); len(got) != 2 {
+		t.Errorf("daily series at the end of 9999 = %q, want 2 occurrences", got)
+	}
+}
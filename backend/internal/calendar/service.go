@@ -1,161 +1,99 @@
 package calendar
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
+	"backend/pkg/serialhandler"
 	"log"
-	"net/http"
-	"net/url"
-	"strings"
 	"time"
 )
 
+// panelTimeFormat is how FromTime and ToTime are sent to the panel
+const panelTimeFormat = "2006-01-02T15:04:05-07:00"
+
+// upcomingWindow is how far ahead the panel counts down to the next meeting
+const upcomingWindow = 2 * time.Hour
+
 type RoomAvailability struct {
 	IsAvailable bool   `json:"isAvailable"`
 	FromTime    string `json:"FromTime"`
 	ToTime      string `json:"ToTime"`
 }
 
-// CheckRoomAvailability checks if the meeting room is available
-func CheckRoomAvailability(roomEmail, accessToken string, startTime, endTime time.Time) (*RoomAvailability, error) {
-	// Format times using RFC3339 and ensure proper URL encoding
-	startTimeStr := url.QueryEscape(startTime.Format("2006-01-02T15:04:05-07:00"))
-	endTimeStr := url.QueryEscape(endTime.Format("2006-01-02T15:04:05-07:00"))
+// Service checks the room availability with events from Provider
+type Service struct {
+	Provider CalendarProvider
+}
 
-	// Microsoft Graph API endpoint
-	url := fmt.Sprintf("https://graph.microsoft.com/v1.0/users/%s/calendarView?startDateTime=%s&endDateTime=%s",
-		roomEmail, startTimeStr, endTimeStr)
-	fmt.Println(url)
+// NewService returns a Service reading from provider
+func NewService(provider CalendarProvider) *Service {
+	return &Service{Provider: provider}
+}
 
-	// Create the HTTP GET request
-	req, err := http.NewRequest("GET", url, nil)
+// Open returns a Service for the provider selected in config
+func Open(config serialhandler.CalendarConfig) (*Service, error) {
+	provider, err := NewProvider(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
+	return NewService(provider), nil
+}
 
-	// Set authorization headers
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-	req.Header.Set("Content-Type", "application/json")
-
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// CheckRoomAvailability checks if the meeting room is available
+func (s *Service) CheckRoomAvailability(room string, startTime, endTime time.Time) (*RoomAvailability, error) {
+	events, err := s.Provider.ListEvents(room, startTime, endTime)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Handle non-200 status codes
-	if resp.StatusCode != http.StatusOK {
-		fmt.Println("not ok status code error!")
-		body, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch calendar view: %s, response: %s", resp.Status, string(body))
+		return nil, err
 	}
+	log.Printf("Number of events retrieved for %s: %d", room, len(events))
+	return Availability(events, time.Now()), nil
+}
 
-	// Parse the response
-	var eventsResp struct {
-		Value []struct {
-			Start struct {
-				DateTime string `json:"dateTime"`
-			} `json:"start"`
-			End struct {
-				DateTime string `json:"dateTime"`
-			} `json:"end"`
-		} `json:"value"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&eventsResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+// Close stops the background work of the provider, e.g. token refreshes
+func (s *Service) Close() {
+	if closer, ok := s.Provider.(interface{ Close() }); ok {
+		closer.Close()
 	}
+}
 
-	log.Printf("Number of events retrieved: %d\n", len(eventsResp.Value))
-
-	// Determine the next relevant time (either when a meeting ends or starts)
-	var startEventTime *time.Time
-	var endEventTime *time.Time
-
-	isCurrentlyBusy := false
-	now := time.Now().UTC().Add(1 * time.Hour) // Adjust to UTC+1
-	twoHoursLater := now.Add(2 * time.Hour)
-
-	fmt.Println("Current Time (UTC+1):", now)
-	fmt.Println("Checking for meetings within next 2 hours until:", twoHoursLater)
-
-	for _, event := range eventsResp.Value {
-		startTimeStr := event.Start.DateTime
-		endTimeStr := event.End.DateTime
-
-		if !strings.HasSuffix(startTimeStr, "Z") && !strings.Contains(startTimeStr, "+") {
-			startTimeStr += "Z"
-		}
-		if !strings.HasSuffix(endTimeStr, "Z") && !strings.Contains(endTimeStr, "+") {
-			endTimeStr += "Z"
-		}
-
-		eventStart, err := time.Parse(time.RFC3339Nano, startTimeStr)
-		if err != nil {
-			log.Printf("Failed to parse start time: %v, Raw Start: %s", err, startTimeStr)
-			continue
-		}
-
-		eventEnd, err := time.Parse(time.RFC3339Nano, endTimeStr)
-		if err != nil {
-			log.Printf("Failed to parse end time: %v, Raw End: %s", err, endTimeStr)
-			continue
-		}
+// panelTime converts t to the UTC+1 wall clock the panel shows
+func panelTime(t time.Time) time.Time {
+	return t.UTC().Add(1 * time.Hour)
+}
 
-		// Convert to UTC+1
-		eventStart = eventStart.UTC().Add(1 * time.Hour)
-		eventEnd = eventEnd.UTC().Add(1 * time.Hour)
+// Availability works out what the panel shows at now. During a meeting the
+// room is busy from its start to its end; otherwise, if a meeting starts
+// within two hours, the panel counts down to it from two hours before.
+func Availability(events []Event, now time.Time) *RoomAvailability {
+	now = panelTime(now)
 
-		log.Printf("Now (UTC+1): %v, Event Start (UTC+1): %v, Event End (UTC+1): %v\n", now, eventStart, eventEnd)
+	var current *Event
+	var next *time.Time
+	for i, event := range events {
+		eventStart := panelTime(event.Start)
+		eventEnd := panelTime(event.End)
 
 		// Check if the room is currently occupied
 		if now.After(eventStart) && now.Before(eventEnd) {
-			isCurrentlyBusy = true
-			startEventTime = &eventStart // Meeting started at this time
-			endEventTime = &eventEnd     // Meeting will end at this time
-			log.Println("Room is currently occupied.")
-			break // Stop checking as the room is already occupied
+			current = &events[i]
+			break
 		}
 
 		// Otherwise, find the next upcoming event within 2 hours
-		if eventStart.After(now) && eventStart.Before(twoHoursLater) {
-			if startEventTime == nil || eventStart.Before(*startEventTime) {
-				startEventTime = &eventStart
+		if eventStart.After(now) && eventStart.Before(now.Add(upcomingWindow)) {
+			if next == nil || eventStart.Before(*next) {
+				next = &eventStart
 			}
 		}
 	}
 
-	var toTime string
-	var fromTime string
-	// Ensure the transition only happens when the current meeting has fully ended
-	if isCurrentlyBusy {
-		toTime = endEventTime.Format("2006-01-02T15:04:05-07:00")
-		fromTime = startEventTime.Format("2006-01-02T15:04:05-07:00")
-	} else if startEventTime != nil {
-		// Ensure we do not switch to the next event prematurely
-		if endEventTime != nil && now.Before(*endEventTime) {
-			// Current meeting is still active, do not transition yet
-			fromTime = startEventTime.Format("2006-01-02T15:04:05-07:00")
-			toTime = endEventTime.Format("2006-01-02T15:04:05-07:00")
-		} else {
-			// No active meeting, schedule the next one
-			toTime = startEventTime.Format("2006-01-02T15:04:05-07:00")
-			fromTime = startEventTime.Add(-2 * time.Hour).Format("2006-01-02T15:04:05-07:00") // Set 2 hours before toTime so its relative to something for the progress bar
-		}
-
+	availability := &RoomAvailability{IsAvailable: current == nil}
+	if current != nil {
+		availability.FromTime = panelTime(current.Start).Format(panelTimeFormat)
+		availability.ToTime = panelTime(current.End).Format(panelTimeFormat)
+	} else if next != nil {
+		// Set 2 hours before ToTime so it is relative to something for the progress bar
+		availability.FromTime = next.Add(-upcomingWindow).Format(panelTimeFormat)
+		availability.ToTime = next.Format(panelTimeFormat)
 	}
-
-	log.Println("EMAIL:", roomEmail)
-	log.Println("Next Event Time:", startEventTime)
-	log.Println("Server Time (UTC+1):", time.Now().UTC().Add(1*time.Hour))
-	log.Println("Next Meeting Starts At:", fromTime)
-	log.Println("Current Meeting Ends At:", toTime)
-
-	return &RoomAvailability{
-		IsAvailable: !isCurrentlyBusy,
-		FromTime:    fromTime,
-		ToTime:      toTime,
-	}, nil
+	log.Printf("Now (UTC+1): %v, available: %t, from: %s, to: %s", now, availability.IsAvailable, availability.FromTime, availability.ToTime)
+	return availability
 }
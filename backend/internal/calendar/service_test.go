@@ -0,0 +1,91 @@
+package calendar
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAvailability(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 30, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time { return time.Date(2026, 3, 2, hour, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		events []Event
+		want   RoomAvailability
+	}{
+		{"no events", nil, RoomAvailability{IsAvailable: true}},
+		{
+			"in a meeting",
+			[]Event{{Start: at(10, 0), End: at(11, 0)}},
+			RoomAvailability{FromTime: "2026-03-02T11:00:00+00:00", ToTime: "2026-03-02T12:00:00+00:00"},
+		},
+		{
+			"meeting within two hours",
+			[]Event{{Start: at(11, 15), End: at(12, 0)}},
+			RoomAvailability{IsAvailable: true, FromTime: "2026-03-02T10:15:00+00:00", ToTime: "2026-03-02T12:15:00+00:00"},
+		},
+		{
+			"the earliest upcoming meeting counts",
+			[]Event{{Start: at(12, 0), End: at(13, 0)}, {Start: at(11, 0), End: at(11, 30)}},
+			RoomAvailability{IsAvailable: true, FromTime: "2026-03-02T10:00:00+00:00", ToTime: "2026-03-02T12:00:00+00:00"},
+		},
+		{"meeting more than two hours away", []Event{{Start: at(13, 0), End: at(14, 0)}}, RoomAvailability{IsAvailable: true}},
+		{"meeting that ended", []Event{{Start: at(9, 0), End: at(10, 30)}}, RoomAvailability{IsAvailable: true}},
+		{
+			"a current meeting wins over an upcoming one",
+			[]Event{{Start: at(11, 0), End: at(12, 0)}, {Start: at(10, 0), End: at(11, 0)}},
+			RoomAvailability{FromTime: "2026-03-02T11:00:00+00:00", ToTime: "2026-03-02T12:00:00+00:00"},
+		},
+		{
+			"event times in another zone",
+			[]Event{{Start: at(10, 0).In(time.FixedZone("EST", -5*3600)), End: at(11, 0).In(time.FixedZone("EST", -5*3600))}},
+			RoomAvailability{FromTime: "2026-03-02T11:00:00+00:00", ToTime: "2026-03-02T12:00:00+00:00"},
+		},
+	}
+	for _, test := range tests {
+		if got := Availability(test.events, now); *got != test.want {
+			t.Errorf("%s: Availability = %+v, want %+v", test.name, *got, test.want)
+		}
+	}
+}
+
+// staticProvider returns events, or err, for any room
+type staticProvider struct {
+	events []Event
+	err    error
+}
+
+func (p staticProvider) ListEvents(room string, start, end time.Time) ([]Event, error) {
+	return p.events, p.err
+}
+
+func (p staticProvider) CreateEvent(room string, event Event) (*Event, error) {
+	return nil, ErrReadOnly
+}
+
+func (p staticProvider) UpdateEvent(room string, event Event) (*Event, error) {
+	return nil, ErrReadOnly
+}
+
+func (p staticProvider) DeleteEvent(room string, id string) error {
+	return ErrReadOnly
+}
+
+func TestCheckRoomAvailability(t *testing.T) {
+	now := time.Now()
+	service := NewService(staticProvider{events: []Event{{Start: now.Add(-time.Minute), End: now.Add(time.Hour)}}})
+	availability, err := service.CheckRoomAvailability("room@example.com", now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("CheckRoomAvailability: %v", err)
+	}
+	if availability.IsAvailable {
+		t.Error("room is available during a meeting")
+	}
+
+	failing := NewService(staticProvider{err: errors.New("calendar down")})
+	if _, err := failing.CheckRoomAvailability("room@example.com", now, now.Add(time.Hour)); err == nil {
+		t.Error("CheckRoomAvailability hid the provider error")
+	}
+}
@@ -0,0 +1,147 @@
+package calendar
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// googleTokenURL is the OAuth token endpoint used when neither the config nor the key file set one
+const googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// googleCalendarScope gives read and write access to calendars
+const googleCalendarScope = "https://www.googleapis.com/auth/calendar"
+
+// ServiceAccount is the JSON key file of a Google service account
+type ServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// LoadServiceAccount reads a service account key file downloaded from the Google Cloud console
+func LoadServiceAccount(path string) (*ServiceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key: %w", err)
+	}
+	var account ServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("failed to parse service account key: %w", err)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("service account key %s has no client_email or private_key", path)
+	}
+	return &account, nil
+}
+
+// ServiceAccountTokenIssuer requests tokens with a JWT signed locally with
+// the account's key (RS256). subject, if set, is the Workspace user the
+// account acts as with domain-wide delegation. tokenURL overrides the token
+// endpoint of the key file, e.g. to use a fake one.
+func ServiceAccountTokenIssuer(account *ServiceAccount, subject, tokenURL string) (TokenIssuer, error) {
+	key, err := parsePrivateKey(account.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if tokenURL == "" {
+		tokenURL = account.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = googleTokenURL
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	return func() (string, time.Duration, error) {
+		now := time.Now()
+		claims := map[string]any{
+			"iss":   account.ClientEmail,
+			"scope": googleCalendarScope,
+			"aud":   tokenURL,
+			"iat":   now.Unix(),
+			"exp":   now.Add(time.Hour).Unix(),
+		}
+		if subject != "" {
+			claims["sub"] = subject
+		}
+		assertion, err := signJWT(key, claims)
+		if err != nil {
+			return "", 0, err
+		}
+
+		form := url.Values{}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+		resp, err := client.PostForm(tokenURL, form)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed to send request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return "", 0, fmt.Errorf("failed to get access token: %s, response: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		var tokenResponse struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int    `json:"expires_in"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+			return "", 0, fmt.Errorf("failed to decode token response: %w", err)
+		}
+		return tokenResponse.AccessToken, time.Duration(tokenResponse.ExpiresIn) * time.Second, nil
+	}, nil
+}
+
+// parsePrivateKey reads the PEM encoded RSA key of a key file, PKCS#8 or PKCS#1
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, fmt.Errorf("service account private_key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse service account private_key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("service account private_key is not an RSA key")
+	}
+	return key, nil
+}
+
+// signJWT returns the RS256 signed JWT with claims
+func signJWT(key *rsa.PrivateKey, claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
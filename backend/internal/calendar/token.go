@@ -0,0 +1,134 @@
+package calendar
+
+import (
+	"backend/pkg/utils"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// refreshMargin is how long before it expires a token is replaced
+	refreshMargin = 5 * time.Minute
+	// refreshRetry is how soon a failed background refresh is tried again
+	refreshRetry = 30 * time.Second
+	// minRefreshDelay keeps issuers of very short-lived tokens from being asked in a loop
+	minRefreshDelay = 30 * time.Second
+	// defaultTokenLifetime is assumed when the issuer does not say how long a token is valid
+	defaultTokenLifetime = 10 * time.Minute
+)
+
+// TokenSource hands out bearer tokens for the calendar API
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// TokenIssuer requests a new token and returns how long it is valid, e.g.
+// from login.microsoftonline.com or from a fake issuer in tests
+type TokenIssuer func() (string, time.Duration, error)
+
+// GraphTokenIssuer requests Microsoft Graph tokens with the client credentials flow
+func GraphTokenIssuer(clientID, clientSecret, tenantID string) TokenIssuer {
+	return func() (string, time.Duration, error) {
+		return utils.RequestAccessToken(clientID, clientSecret, tenantID)
+	}
+}
+
+// CachedTokenSource reuses a token until shortly before it expires and
+// refreshes it in the background, so requests rarely wait for the issuer.
+// Concurrent callers share one refresh.
+type CachedTokenSource struct {
+	issue TokenIssuer
+
+	mu      sync.Mutex
+	token   string
+	refresh time.Time
+	expires time.Time
+	timer   *time.Timer
+	closed  bool
+}
+
+// NewCachedTokenSource caches the tokens of issue, nothing is requested before the first Token call
+func NewCachedTokenSource(issue TokenIssuer) *CachedTokenSource {
+	return &CachedTokenSource{issue: issue}
+}
+
+// Token returns the cached token, or requests one if there is none or it is about to expire
+func (c *CachedTokenSource) Token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.refresh) {
+		return c.token, nil
+	}
+	if err := c.renew(); err != nil {
+		// A token that is due for refresh may still be valid for a while
+		if c.token != "" && time.Now().Before(c.expires) {
+			log.Printf("Token refresh failed, using the current token: %v", err)
+			return c.token, nil
+		}
+		return "", err
+	}
+	return c.token, nil
+}
+
+// Close stops the background refresh
+func (c *CachedTokenSource) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// renew requests a new token and schedules the next refresh, must be called with c.mu held
+func (c *CachedTokenSource) renew() error {
+	token, expiresIn, err := c.issue()
+	if err != nil {
+		c.schedule(refreshRetry)
+		return err
+	}
+	if token == "" {
+		c.schedule(refreshRetry)
+		return errors.New("token issuer returned an empty token")
+	}
+	if expiresIn <= 0 {
+		log.Printf("Token issuer returned no expiry, assuming %s", defaultTokenLifetime)
+		expiresIn = defaultTokenLifetime
+	}
+
+	margin := refreshMargin
+	if expiresIn < 4*margin {
+		margin = expiresIn / 4
+	}
+
+	now := time.Now()
+	c.token = token
+	c.expires = now.Add(expiresIn)
+	c.refresh = c.expires.Add(-margin)
+	c.schedule(max(c.refresh.Sub(now), minRefreshDelay))
+	log.Printf("Access token renewed, valid for %s", expiresIn)
+	return nil
+}
+
+// schedule refreshes the token in the background after delay, must be called with c.mu held
+func (c *CachedTokenSource) schedule(delay time.Duration) {
+	if c.closed {
+		return
+	}
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.timer = time.AfterFunc(delay, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.closed {
+			return
+		}
+		if err := c.renew(); err != nil {
+			log.Printf("Background token refresh failed: %v", err)
+		}
+	})
+}
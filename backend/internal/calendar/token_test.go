@@ -0,0 +1,151 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIssuer hands out numbered tokens valid for lifetime, or fails with err
+type fakeIssuer struct {
+	mu       sync.Mutex
+	calls    int
+	lifetime time.Duration
+	delay    time.Duration
+	err      error
+}
+
+func (f *fakeIssuer) issue() (string, time.Duration, error) {
+	time.Sleep(f.delay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.err != nil {
+		return "", 0, f.err
+	}
+	return fmt.Sprintf("token-%d", f.calls), f.lifetime, nil
+}
+
+func (f *fakeIssuer) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *fakeIssuer) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func TestCachedTokenSourceReusesToken(t *testing.T) {
+	issuer := &fakeIssuer{lifetime: time.Hour}
+	source := NewCachedTokenSource(issuer.issue)
+	defer source.Close()
+
+	for range 3 {
+		token, err := source.Token()
+		if err != nil || token != "token-1" {
+			t.Fatalf("Token() = %q, %v, want token-1", token, err)
+		}
+	}
+	if calls := issuer.callCount(); calls != 1 {
+		t.Errorf("issuer called %d times, want 1", calls)
+	}
+	if want := time.Now().Add(time.Hour - refreshMargin); source.refresh.After(want) {
+		t.Errorf("refresh at %s, want at least %s before expiry", source.refresh, refreshMargin)
+	}
+}
+
+func TestCachedTokenSourceSharesRefresh(t *testing.T) {
+	issuer := &fakeIssuer{lifetime: time.Hour, delay: 50 * time.Millisecond}
+	source := NewCachedTokenSource(issuer.issue)
+	defer source.Close()
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := source.Token(); err != nil {
+				t.Errorf("Token: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls := issuer.callCount(); calls != 1 {
+		t.Errorf("issuer called %d times for concurrent callers, want 1", calls)
+	}
+}
+
+func TestCachedTokenSourceRefreshFailure(t *testing.T) {
+	issuer := &fakeIssuer{lifetime: time.Hour}
+	source := NewCachedTokenSource(issuer.issue)
+	defer source.Close()
+
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	// Due for refresh but still valid: the current token is kept
+	issuer.fail(errors.New("issuer down"))
+	source.mu.Lock()
+	source.refresh = time.Now()
+	source.mu.Unlock()
+	if token, err := source.Token(); err != nil || token != "token-1" {
+		t.Errorf("Token() = %q, %v, want the still valid token-1", token, err)
+	}
+
+	// Expired: the error is returned
+	source.mu.Lock()
+	source.refresh, source.expires = time.Now(), time.Now()
+	source.mu.Unlock()
+	if _, err := source.Token(); err == nil {
+		t.Error("Token() of an expired token with a failing issuer succeeded")
+	}
+}
+
+func TestCachedTokenSourceWithoutExpiry(t *testing.T) {
+	// expires_in missing or 0 must not make the background refresh spin
+	issuer := &fakeIssuer{}
+	source := NewCachedTokenSource(issuer.issue)
+	defer source.Close()
+
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if calls := issuer.callCount(); calls != 1 {
+		t.Errorf("issuer called %d times, want 1", calls)
+	}
+	if source.expires.Before(time.Now().Add(defaultTokenLifetime - time.Minute)) {
+		t.Errorf("token expires at %s, want the default lifetime of %s", source.expires, defaultTokenLifetime)
+	}
+}
+
+func TestCachedTokenSourceShortLifetime(t *testing.T) {
+	issuer := &fakeIssuer{lifetime: time.Second}
+	source := NewCachedTokenSource(issuer.issue)
+	defer source.Close()
+
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	// The background refresh waits at least minRefreshDelay
+	time.Sleep(200 * time.Millisecond)
+	if calls := issuer.callCount(); calls != 1 {
+		t.Errorf("issuer called %d times within 200ms, want 1", calls)
+	}
+}
+
+func TestCachedTokenSourceIssuerError(t *testing.T) {
+	issuer := &fakeIssuer{err: errors.New("invalid client secret")}
+	source := NewCachedTokenSource(issuer.issue)
+	defer source.Close()
+
+	if _, err := source.Token(); err == nil {
+		t.Error("Token() succeeded with a failing issuer")
+	}
+}
@@ -3,7 +3,6 @@ package handlers
 import (
 	"backend/internal/calendar"
 	"backend/pkg/serialhandler"
-	"backend/pkg/utils"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,24 +11,24 @@ import (
 	"net/http"
 	"os"
 	"time"
-
-	"github.com/joho/godotenv"
 )
 
-// Initialize a logger to write to serverlog.txt
-var serverLogger *log.Logger
+// serverLogger logs the calendar requests, to the console until OpenServerLog adds the log file
+var serverLogger = log.New(os.Stdout, "SERVER: ", log.Ldate|log.Ltime|log.Lshortfile)
 
-func init() {
-	// Open or create the log file
-	logFile, err := os.OpenFile("serverlog.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+// OpenServerLog makes the calendar requests also go to the log file at path,
+// the returned file is closed when the server stops
+func OpenServerLog(path string) (io.Closer, error) {
+	logFile, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
+		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
 
 	// Log to both file and console
 	multiWriter := io.MultiWriter(os.Stdout, logFile)
 	serverLogger = log.New(multiWriter, "SERVER: ", log.Ldate|log.Ltime|log.Lshortfile)
 	serverLogger.Println("Logging started")
+	return logFile, nil
 }
 
 type Time struct {
@@ -53,35 +52,10 @@ type RoomAvailabilityResponse struct {
 	Error            string                     `json:"error,omitempty"`
 }
 
-func loadEnv() error {
-	return godotenv.Load()
-}
-
 // Handler to get current meeting status and log the response
 func (h *Handlers) GetCurrentMeetingStatusFromEnv(w http.ResponseWriter, r *http.Request) {
 	serverLogger.Println("Received request for GetCurrentMeetingStatusFromEnv")
 
-	// Load environment variables
-	if err := loadEnv(); err != nil {
-		serverLogger.Printf("Error loading .env file: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to load environment variables: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	// Fetch required credentials from environment variables
-	clientID := os.Getenv("CLIENT_ID")
-	clientSecret := os.Getenv("CLIENT_SECRET")
-	tenantID := os.Getenv("TENANT_ID")
-
-	// Get access token
-	accessToken, err := utils.GetAccessToken(clientID, clientSecret, tenantID)
-	if err != nil {
-		serverLogger.Printf("Failed to get access token: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to get access token: %v", err), http.StatusInternalServerError)
-		return
-	}
-	serverLogger.Println("Access token retrieved successfully")
-
 	// Define the time range for availability check
 	now := time.Now()
 	startTime := time.Date(now.Year(), now.Month(), now.Day(), 1, 0, 0, 0, now.Location()) // 01:00 AM today
@@ -93,7 +67,7 @@ func (h *Handlers) GetCurrentMeetingStatusFromEnv(w http.ResponseWriter, r *http
 	// Initialize the response struct
 	var roomResponse RoomAvailabilityResponse
 
-	availability, err := calendar.CheckRoomAvailability(roomEmail, accessToken, startTime, endTime)
+	availability, err := h.Calendar.CheckRoomAvailability(roomEmail, startTime, endTime)
 	if err != nil {
 		serverLogger.Printf("Error checking room availability for %s: %v", roomEmail, err)
 		roomResponse = RoomAvailabilityResponse{
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"backend/pkg/room"
+	"backend/pkg/serialhandler"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Device is a device as listed by the API
+type Device struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Driver string `json:"driver"`
+	// Connection is only reported for switchers
+	Connection *serialhandler.ConnectionStatus `json:"connection,omitempty"`
+}
+
+// GetDevices lists the devices of the room in config.json order
+func (h *Handlers) GetDevices(w http.ResponseWriter, r *http.Request) {
+	devices := []Device{}
+	for _, config := range h.Config.DeviceList() {
+		device := Device{Name: config.Name, Type: config.Type, Driver: config.Driver}
+		if config.Type == serialhandler.DeviceDisplay {
+			device.Driver = config.Display.Driver
+			if device.Driver == "" {
+				device.Driver = "wol"
+			}
+		} else if switcher, err := h.Room.Switcher(config.Name); err == nil {
+			status := switcher.Port.Status()
+			device.Connection = &status
+		}
+		if device.Driver == "" {
+			device.Driver = "generic"
+		}
+		devices = append(devices, device)
+	}
+	writeJSON(w, devices)
+}
+
+// GetDeviceState returns the state of one switcher or the status of one display
+func (h *Handlers) GetDeviceState(w http.ResponseWriter, r *http.Request) {
+	if display, ok := h.namedDisplay(r); ok {
+		status, err := display.Driver.QueryStatus()
+		if err != nil {
+			log.Printf("Failed to query %s status: %v", display.Name, err)
+			writeDisplayError(w, err)
+			return
+		}
+		writeJSON(w, status)
+		return
+	}
+
+	switcher, err := h.switcher(r)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	state, err := switcher.Driver.QueryState()
+	if err != nil {
+		log.Printf("Failed to query %s state: %v", switcher.Name, err)
+		writeDriverError(w, err)
+		return
+	}
+	writeJSON(w, state)
+}
+
+// SendCommand sends a command from the device's labeled_commands, e.g. a preset of an audio DSP
+func (h *Handlers) SendCommand(w http.ResponseWriter, r *http.Request) {
+	switcher, err := h.switcher(r)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	label := mux.Vars(r)["label"]
+	command, ok := switcher.Config.LabeledCommands[label]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown command %q for %s", label, switcher.Name), http.StatusNotFound)
+		return
+	}
+
+	if err := switcher.Port.Write(command); err != nil {
+		log.Printf("Command %s on %s failed: %v", label, switcher.Name, err)
+		writeDriverError(w, err)
+		return
+	}
+	writeJSON(w, ActionResponse{Action: fmt.Sprintf("Sent %s to %s", label, switcher.Name)})
+}
+
+// writeDeviceError reports a device that is not in config.json
+func writeDeviceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, room.ErrUnknownDevice) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"backend/pkg/display"
+	"backend/pkg/room"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// SelectDisplayInput switches the display itself, in the display driver's input names
+func (h *Handlers) SelectDisplayInput(w http.ResponseWriter, r *http.Request) {
+	display, err := h.Room.Display(mux.Vars(r)["device"])
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	selectDisplayInput(w, display, mux.Vars(r)["input"])
+}
+
+// SetDisplayVolume sets the display volume from 0 to 100
+func (h *Handlers) SetDisplayVolume(w http.ResponseWriter, r *http.Request) {
+	display, err := h.Room.Display(mux.Vars(r)["device"])
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	level, err := strconv.Atoi(mux.Vars(r)["level"])
+	if err != nil || level < 0 || level > 100 {
+		http.Error(w, "Invalid volume, expected 0-100", http.StatusBadRequest)
+		return
+	}
+
+	if err := display.Driver.SetVolume(level); err != nil {
+		log.Printf("Setting volume of %s to %d failed: %v", display.Name, level, err)
+		writeDisplayError(w, err)
+		return
+	}
+	writeJSON(w, ActionResponse{Action: fmt.Sprintf("Set display volume to %d", level)})
+}
+
+func selectDisplayInput(w http.ResponseWriter, tv *room.Display, input string) {
+	if err := tv.Driver.SelectInput(input); err != nil {
+		log.Printf("Selecting input %s on %s failed: %v", input, tv.Name, err)
+		writeDisplayError(w, err)
+		return
+	}
+	writeJSON(w, ActionResponse{Action: fmt.Sprintf("Selected display input %s", input)})
+}
+
+func setDisplayPower(w http.ResponseWriter, tv *room.Display, on bool) {
+	if on {
+		result := tv.Wake()
+		response := ActionResponse{Action: "Turned on display", Displays: map[string]display.WakeResult{tv.Name: result}}
+		if !result.Ready {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+		writeJSON(w, response)
+		return
+	}
+
+	if err := tv.Driver.PowerOff(); err != nil {
+		log.Printf("Turning off %s failed: %v", tv.Name, err)
+		writeDisplayError(w, err)
+		return
+	}
+	writeJSON(w, ActionResponse{Action: "Turned off display"})
+}
+
+// writeDisplayError maps display errors to an HTTP status like writeDriverError
+func writeDisplayError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, display.ErrUnsupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	case errors.Is(err, display.ErrUnknownInput):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), driverErrorStatus(err))
+	}
+}
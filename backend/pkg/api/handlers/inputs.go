@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"backend/pkg/display"
+	"backend/pkg/room"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Input is an input as listed by the API, without its switcher command
+type Input struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+	Icon        string `json:"icon"`
+}
+
+// ActionResponse is returned by the input and power endpoints
+type ActionResponse struct {
+	Action  string `json:"action"`
+	Display string `json:"display,omitempty"`
+	// Displays has the outcome of powering on each display
+	Displays map[string]display.WakeResult `json:"displays,omitempty"`
+	// Shutdown has the outcome of turning off each device of the room
+	Shutdown *room.ShutdownResult `json:"shutdown,omitempty"`
+	// Error says why the action was not done
+	Error string `json:"error,omitempty"`
+}
+
+// GetInputs lists the inputs of the switcher from config.json
+func (h *Handlers) GetInputs(w http.ResponseWriter, r *http.Request) {
+	switcher, err := h.switcher(r)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	inputs := []Input{}
+	for _, input := range switcher.Config.InputList() {
+		inputs = append(inputs, Input{
+			ID:          input.ID,
+			Name:        input.Name,
+			DisplayName: input.DisplayName,
+			Icon:        input.Icon,
+		})
+	}
+	writeJSON(w, inputs)
+}
+
+// SelectInput powers on the display and switches to the named input. On a
+// device route only that device is switched, which may also be a display.
+func (h *Handlers) SelectInput(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if display, ok := h.namedDisplay(r); ok {
+		selectDisplayInput(w, display, name)
+		return
+	}
+
+	switcher, err := h.switcher(r)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	input, ok := switcher.Config.InputByName(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown input %q", name), http.StatusNotFound)
+		return
+	}
+
+	response := ActionResponse{Action: fmt.Sprintf("Selected input %s", name)}
+	if mux.Vars(r)["device"] == "" {
+		response.Display, response.Displays = powerOnDisplay(h)
+		if err := h.displaysReady(response.Displays); err != nil {
+			writeNotReady(w, response, err, "Display not ready, input not selected")
+			return
+		}
+	}
+	if err := switcher.Driver.SelectInput(input.ID); err != nil {
+		log.Printf("Selecting input %s failed: %v", name, err)
+		writeDriverError(w, err)
+		return
+	}
+	writeJSON(w, response)
+}
+
+// SetPower turns the displays and the switcher output on, or shuts the room
+// down. On a device route only that device is turned on or off.
+func (h *Handlers) SetPower(w http.ResponseWriter, r *http.Request) {
+	state := mux.Vars(r)["state"]
+	if state != "on" && state != "off" {
+		http.Error(w, fmt.Sprintf("Invalid power state %q, expected on or off", state), http.StatusBadRequest)
+		return
+	}
+	if display, ok := h.namedDisplay(r); ok {
+		setDisplayPower(w, display, state == "on")
+		return
+	}
+
+	wholeRoom := mux.Vars(r)["device"] == ""
+	if wholeRoom && state == "off" {
+		h.Shutdown(w, r)
+		return
+	}
+
+	switcher, err := h.switcher(r)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	var response ActionResponse
+	if state == "on" {
+		response.Action = "Turned on output"
+		if wholeRoom {
+			response.Display, response.Displays = powerOnDisplay(h)
+			if err := h.displaysReady(response.Displays); err != nil {
+				writeNotReady(w, response, err, "Display not ready, output not turned on")
+				return
+			}
+		}
+		err = switcher.Driver.PowerOn()
+	} else {
+		response.Action = "Turned off output"
+		err = switcher.Driver.PowerOff()
+	}
+
+	if err != nil {
+		log.Printf("%s failed: %v", response.Action, err)
+		writeDriverError(w, err)
+		return
+	}
+	writeJSON(w, response)
+}
+
+// writeNotReady answers a request the switcher was not told about since a
+// display is not ready, with the wake results like the button API
+func writeNotReady(w http.ResponseWriter, response ActionResponse, err error, message string) {
+	log.Printf("%s failed: %v", response.Action, err)
+	response.Error = message
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	writeJSON(w, response)
+}
+
+// writeJSON encodes the response body as JSON
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
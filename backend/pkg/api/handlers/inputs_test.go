@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"backend/pkg/serialhandler"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// newInputHandlers is newButtonHandlers with named inputs on the switcher
+func newInputHandlers(switcher *fakeSwitcher, tv *fakeDisplay) *Handlers {
+	h := newButtonHandlers(switcher, tv)
+	h.Room.Switchers[0].Config = serialhandler.Config{Inputs: []serialhandler.InputConfig{
+		{ID: 1, Name: "wireless", Command: "sw i01"},
+		{ID: 2, Name: "room_pc", Command: "sw i02"},
+	}}
+	return h
+}
+
+// call runs handler with the route variables and decodes the ActionResponse
+func call(t *testing.T, handler http.HandlerFunc, vars map[string]string) (int, ActionResponse) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler(recorder, mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", nil), vars))
+	var response ActionResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("status %d, invalid response: %v", recorder.Code, err)
+	}
+	return recorder.Code, response
+}
+
+func TestSelectInputWaitsForDisplay(t *testing.T) {
+	switcher := &fakeSwitcher{}
+	h := newInputHandlers(switcher, &fakeDisplay{on: true})
+	status, response := call(t, h.SelectInput, map[string]string{"name": "room_pc"})
+	if status != http.StatusOK || !response.Displays["tv"].Ready {
+		t.Fatalf("status = %d, displays = %+v, want 200 with tv ready", status, response.Displays)
+	}
+	if len(switcher.inputs) != 1 || switcher.inputs[0] != 2 {
+		t.Errorf("inputs sent = %v, want [2]", switcher.inputs)
+	}
+
+	switcher = &fakeSwitcher{}
+	h = newInputHandlers(switcher, &fakeDisplay{})
+	status, response = call(t, h.SelectInput, map[string]string{"name": "room_pc"})
+	if status != http.StatusGatewayTimeout || response.Error == "" || response.Displays["tv"].Ready {
+		t.Fatalf("status = %d, response = %+v, want 504 with tv not ready", status, response)
+	}
+	if len(switcher.inputs) != 0 {
+		t.Errorf("inputs sent = %v, want none while the display is not ready", switcher.inputs)
+	}
+}
+
+func TestPowerOnWaitsForDisplay(t *testing.T) {
+	switcher := &fakeSwitcher{}
+	h := newInputHandlers(switcher, &fakeDisplay{on: true})
+	if status, _ := call(t, h.SetPower, map[string]string{"state": "on"}); status != http.StatusOK || switcher.powerOns != 1 {
+		t.Errorf("status = %d with %d power on commands, want 200 and 1", status, switcher.powerOns)
+	}
+
+	switcher = &fakeSwitcher{}
+	h = newInputHandlers(switcher, &fakeDisplay{})
+	status, response := call(t, h.SetPower, map[string]string{"state": "on"})
+	if status != http.StatusGatewayTimeout || response.Error == "" {
+		t.Errorf("status = %d, response = %+v, want 504 with the reason", status, response)
+	}
+	if switcher.powerOns != 0 {
+		t.Errorf("%d power on commands sent while the display is not ready", switcher.powerOns)
+	}
+}
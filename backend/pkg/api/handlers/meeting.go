@@ -1,19 +1,23 @@
 package handlers
 
 import (
+	"backend/pkg/display"
+	"backend/pkg/room"
+	"backend/pkg/serialhandler"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 )
 
+// HandleButtonClick is the original button API, kept for older panels.
+// 0 and 1 are turn off / on, the inputs are offset by one from there.
 func (h *Handlers) HandleButtonClick(w http.ResponseWriter, r *http.Request) {
-	// Make sure the TV is on //
-	wolMessage := sendWakeOnLan(h)
-	w.Write([]byte(fmt.Sprintf("Wake on LAN: %s\n", wolMessage)))
-
-	// Continue button handler logic
 	vars := mux.Vars(r)
 	buttonID, err := strconv.Atoi(vars["id"]) // Convert ID to integer
 	if err != nil {
@@ -21,18 +25,174 @@ func (h *Handlers) HandleButtonClick(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	commandKey := fmt.Sprintf("input_%d", buttonID-1) // -1 because offsets on default inputs since turn off / on is reserved for the 0 and 1 IDs
-	command := h.Port.Config.LabeledCommands[commandKey]
-	if buttonID == 0 {
-		command = h.Port.Config.LabeledCommands["turn_off"]
+	switcher, err := h.Room.Switcher("")
+	if err != nil {
+		writeDeviceError(w, err)
+		return
 	}
-	if buttonID == 1 {
-		command = h.Port.Config.LabeledCommands["turn_on"]
+
+	var action string
+	var displays map[string]display.WakeResult
+	var shutdown *room.ShutdownResult
+	switch buttonID {
+	case 0:
+		action = "Turned off output"
+		shutdown, err = h.Room.Shutdown(h.Config.ShutdownSteps())
+	case 1:
+		// Make sure the TV is on //
+		_, displays = powerOnDisplay(h)
+		action = "Turned on output"
+		if err = h.displaysReady(displays); err == nil {
+			err = switcher.Driver.PowerOn()
+		}
+	default:
+		// The input is not switched while a display is still booting, the
+		// panel would show the input as selected on a dark screen
+		_, displays = powerOnDisplay(h)
+		action = fmt.Sprintf("Selected input %d", buttonID-1)
+		if err = h.displaysReady(displays); err == nil {
+			err = switcher.Driver.SelectInput(buttonID - 1)
+		}
 	}
 
-	if err := h.Port.Write(command); err != nil {
-		http.Error(w, "Failed to send command", http.StatusInternalServerError)
+	if err != nil {
+		log.Printf("Button %d failed: %v", buttonID, err)
+		status := driverErrorStatus(err)
+		message := driverErrorMessage(err, status)
+		if errors.Is(err, errDisplayNotReady) {
+			status, message = http.StatusGatewayTimeout, "Display not ready, input not selected"
+			if buttonID == 1 {
+				message = "Display not ready, output not turned on"
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		writeButtonResults(w, displays, shutdown)
+		fmt.Fprintln(w, message)
 		return
 	}
-	w.Write([]byte(fmt.Sprintf("Sent command: %s", command)))
+	writeButtonResults(w, displays, shutdown)
+	w.Write([]byte(action))
+}
+
+// errDisplayNotReady is returned by a button that needs a display which did not wake up
+var errDisplayNotReady = errors.New("display not ready")
+
+// displaysReady returns errDisplayNotReady when a display with a ready probe did
+// not wake up. Without a probe there is nothing to wait for, a display whose
+// power on failed does not hold back the switcher.
+func (h *Handlers) displaysReady(results map[string]display.WakeResult) error {
+	var names []string
+	for _, d := range h.Room.Displays {
+		if result, ok := results[d.Name]; ok && !result.Ready && d.Config.Ready.Probe != "" {
+			names = append(names, d.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%w: %s", errDisplayNotReady, strings.Join(names, ", "))
+}
+
+// writeButtonResults writes one line per display woken and per device shut down
+func writeButtonResults(w http.ResponseWriter, displays map[string]display.WakeResult, shutdown *room.ShutdownResult) {
+	names := make([]string, 0, len(displays))
+	for name := range displays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result := displays[name]
+		if result.Ready {
+			fmt.Fprintf(w, "Display %s: ready after %d attempts (%s)\n", name, result.Attempts, result.Waited)
+		} else {
+			fmt.Fprintf(w, "Display %s: not ready after %d attempts (%s): %s\n", name, result.Attempts, result.Waited, result.Error)
+		}
+	}
+	if shutdown != nil {
+		for _, device := range shutdown.Devices {
+			fmt.Fprintf(w, "%s: %s\n", device.Device, device.Status)
+		}
+	}
+}
+
+// GetSwitcherStatus reports whether the serial link to the switcher is up
+func (h *Handlers) GetSwitcherStatus(w http.ResponseWriter, r *http.Request) {
+	switcher, err := h.switcher(r)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+	writeJSON(w, switcher.Port.Status())
+}
+
+// RoomState is the switcher state with the display status next to it
+type RoomState struct {
+	serialhandler.State
+	Display      *display.Status `json:"display,omitempty"`
+	DisplayError string          `json:"displayError,omitempty"`
+}
+
+// GetState returns the active input, the power state and when they were last
+// confirmed. An unreachable display is reported without failing the request.
+func (h *Handlers) GetState(w http.ResponseWriter, r *http.Request) {
+	switcher, err := h.Room.Switcher("")
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	state, err := switcher.Driver.QueryState()
+	if err != nil {
+		log.Printf("Failed to query switcher state: %v", err)
+		writeDriverError(w, err)
+		return
+	}
+
+	response := RoomState{State: state}
+	if display, err := h.Room.Display(""); err == nil {
+		if status, err := display.Driver.QueryStatus(); err != nil {
+			log.Printf("Failed to query display status: %v", err)
+			response.DisplayError = err.Error()
+		} else {
+			response.Display = &status
+		}
+	}
+	writeJSON(w, response)
+}
+
+// writeDriverError maps switcher errors to an HTTP status
+func writeDriverError(w http.ResponseWriter, err error) {
+	status := driverErrorStatus(err)
+	http.Error(w, driverErrorMessage(err, status), status)
+}
+
+// driverErrorMessage is the text sent with the status of driverErrorStatus
+func driverErrorMessage(err error, status int) string {
+	switch status {
+	case http.StatusServiceUnavailable:
+		return "Switcher offline"
+	case http.StatusGatewayTimeout:
+		return "Switcher did not acknowledge the command"
+	case http.StatusBadGateway, http.StatusNotImplemented:
+		return err.Error()
+	default:
+		return "Failed to send command"
+	}
+}
+
+func driverErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, serialhandler.ErrOffline):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, serialhandler.ErrNoAck):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, serialhandler.ErrDeviceRejected):
+		return http.StatusBadGateway
+	case errors.Is(err, serialhandler.ErrUnsupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
 }
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"backend/pkg/display"
+	"backend/pkg/room"
+	"backend/pkg/serialhandler"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeSwitcher records the commands a handler sends
+type fakeSwitcher struct {
+	mu       sync.Mutex
+	inputs   []int
+	powerOns int
+	powerErr error
+}
+
+func (s *fakeSwitcher) SelectInput(input int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inputs = append(s.inputs, input)
+	return nil
+}
+
+func (s *fakeSwitcher) PowerOn() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.powerOns++
+	return nil
+}
+
+func (s *fakeSwitcher) PowerOff() error { return s.powerErr }
+
+func (s *fakeSwitcher) QueryState() (serialhandler.State, error) {
+	return serialhandler.State{}, nil
+}
+
+func (s *fakeSwitcher) Close() {}
+
+// fakeDisplay powers on only when on is set
+type fakeDisplay struct {
+	on bool
+}
+
+func (d *fakeDisplay) PowerOn() error                 { return nil }
+func (d *fakeDisplay) PowerOff() error                { return nil }
+func (d *fakeDisplay) SelectInput(input string) error { return nil }
+func (d *fakeDisplay) SetVolume(level int) error      { return nil }
+func (d *fakeDisplay) Close()                         {}
+func (d *fakeDisplay) QueryStatus() (display.Status, error) {
+	if d.on {
+		return display.Status{Power: serialhandler.PowerStateOn}, nil
+	}
+	return display.Status{Power: serialhandler.PowerStateStandby}, nil
+}
+
+func newButtonHandlers(switcher *fakeSwitcher, tv *fakeDisplay) *Handlers {
+	ready := serialhandler.ReadyConfig{Probe: display.ProbePower, TimeoutMs: 50, RetryIntervalMs: 50}
+	return &Handlers{
+		Room: &room.Room{
+			Switchers: []*room.Switcher{{Name: "switcher", Driver: switcher}},
+			Displays:  []*room.Display{{Name: "tv", Config: serialhandler.DisplayConfig{Ready: ready}, Driver: tv}},
+		},
+		Config: &serialhandler.Config{},
+	}
+}
+
+func clickButton(h *Handlers, id string) *httptest.ResponseRecorder {
+	request := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/button/"+id, nil), map[string]string{"id": id})
+	recorder := httptest.NewRecorder()
+	h.HandleButtonClick(recorder, request)
+	return recorder
+}
+
+func TestButtonSelectsInputOnceDisplayIsReady(t *testing.T) {
+	switcher := &fakeSwitcher{}
+	response := clickButton(newButtonHandlers(switcher, &fakeDisplay{on: true}), "3")
+
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+	if len(switcher.inputs) != 1 || switcher.inputs[0] != 2 {
+		t.Errorf("inputs sent = %v, want [2]", switcher.inputs)
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, "Display tv: ready") || !strings.Contains(body, "Selected input 2") {
+		t.Errorf("body = %q, want the wake result and the action", body)
+	}
+}
+
+func TestButtonSkipsInputWhenDisplayIsNotReady(t *testing.T) {
+	switcher := &fakeSwitcher{}
+	response := clickButton(newButtonHandlers(switcher, &fakeDisplay{}), "3")
+
+	if response.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504: %s", response.Code, response.Body)
+	}
+	if len(switcher.inputs) != 0 {
+		t.Errorf("inputs sent = %v, want none while the display is not ready", switcher.inputs)
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, "Display tv: not ready") || !strings.Contains(body, "input not selected") {
+		t.Errorf("body = %q, want the wake result and the reason", body)
+	}
+}
+
+func TestButtonReportsShutdownResultsOnError(t *testing.T) {
+	switcher := &fakeSwitcher{powerErr: serialhandler.ErrOffline}
+	response := clickButton(newButtonHandlers(switcher, &fakeDisplay{}), "0")
+
+	if response.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503: %s", response.Code, response.Body)
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, "tv: ok") || !strings.Contains(body, "switcher: failed") {
+		t.Errorf("body = %q, want a line per device", body)
+	}
+}
+
+func TestButtonSelectsInputWithOnlyASwitcher(t *testing.T) {
+	// A config from before devices and displays: the display it gets has no
+	// tv_macaddress to wake and no ready probe to wait for
+	config := &serialhandler.Config{
+		LabeledCommands: map[string]string{"input_1": "sw i01", "input_2": "sw i02", "input_3": "sw i03"},
+		Ack:             serialhandler.AckConfig{SuccessPattern: "(?i)command ok", ErrorPattern: "(?i)command incorrect", TimeoutMs: 500},
+	}
+	simulator := serialhandler.NewSimulator(*config, serialhandler.SimulatorOptions{})
+	devices, err := room.Open(config, func(serialhandler.DeviceConfig) serialhandler.Opener { return simulator.Open })
+	if err != nil {
+		t.Fatalf("room.Open: %v", err)
+	}
+	defer devices.Close()
+
+	response := clickButton(&Handlers{Room: devices, Config: config}, "3")
+	if response.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", response.Code, response.Body)
+	}
+	if state := simulator.State(); state.Input != 2 {
+		t.Errorf("simulator on input %d, want 2", state.Input)
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"backend/pkg/scene"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+)
+
+// Scene is a scene as listed by the API
+type Scene struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// GetScenes lists the scenes from config.json
+func (h *Handlers) GetScenes(w http.ResponseWriter, r *http.Request) {
+	scenes := []Scene{}
+	for name, config := range h.Config.Scenes {
+		scenes = append(scenes, Scene{Name: name, DisplayName: config.DisplayName})
+	}
+	sort.Slice(scenes, func(i, j int) bool { return scenes[i].Name < scenes[j].Name })
+	writeJSON(w, scenes)
+}
+
+// RunScene runs the named scene and reports the outcome of every step
+func (h *Handlers) RunScene(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	result, err := h.Scenes.Run(name)
+	switch {
+	case errors.Is(err, scene.ErrUnknownScene):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, scene.ErrBusy):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Scene %s aborted: %v", name, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(driverErrorStatus(err))
+	}
+	writeJSON(w, result)
+}
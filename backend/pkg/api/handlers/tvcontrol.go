@@ -1,30 +1,64 @@
 package handlers
 
 import (
+	"backend/internal/calendar"
+	"backend/pkg/display"
+	"backend/pkg/room"
+	"backend/pkg/scene"
 	"backend/pkg/serialhandler"
 	"log"
+	"net/http"
 
-	"github.com/linde12/gowol"
+	"github.com/gorilla/mux"
 )
 
 type Handlers struct {
-	Port *serialhandler.Port
+	Room     *room.Room
+	Config   *serialhandler.Config
+	Scenes   *scene.Runner
+	Calendar *calendar.Service
 }
 
-func sendWakeOnLan(h *Handlers) string {
-	ipBroadcast := h.Port.Config.TVBroadcastIP
-	macAddress := h.Port.Config.TVMacAddress
+// switcher returns the switcher named in the route, the first one without a name
+func (h *Handlers) switcher(r *http.Request) (*room.Switcher, error) {
+	return h.Room.Switcher(mux.Vars(r)["device"])
+}
 
-	packet, err := gowol.NewMagicPacket(macAddress)
-	if err != nil {
-		log.Println("Error creating magic packet:", err)
-		log.Println("MACAddress:", macAddress)
-		return "Error creating magic packet"
+// namedDisplay returns the display named in the route, if the route names one
+func (h *Handlers) namedDisplay(r *http.Request) (*room.Display, bool) {
+	name := mux.Vars(r)["device"]
+	if name == "" {
+		return nil, false
+	}
+	display, err := h.Room.Display(name)
+	return display, err == nil
+}
+
+// powerOnDisplay makes sure the TVs or projectors are on and waits until they
+// are ready, so the switch command is not lost while they boot. The outcome
+// is reported next to the switcher action.
+func powerOnDisplay(h *Handlers) (string, map[string]display.WakeResult) {
+	if len(h.Room.Displays) == 0 {
+		return "", nil
 	}
-	if err := packet.Send(ipBroadcast); err != nil {
-		log.Println("Error sending magic packet to broadcast:", err)
-		return "Error sending magic packet to broadcast"
+
+	results := h.Room.WakeAll()
+	for name, result := range results {
+		if !result.Ready {
+			log.Printf("Display %s is not ready: %s", name, result.Error)
+			return "Display not ready", results
+		}
+	}
+	return "Powered on display", results
+}
+
+// Shutdown turns off the displays and switchers in the order from config.json
+func (h *Handlers) Shutdown(w http.ResponseWriter, r *http.Request) {
+	result, err := h.Room.Shutdown(h.Config.ShutdownSteps())
+	if err != nil {
+		log.Printf("Room shutdown failed: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(driverErrorStatus(err))
 	}
-	log.Println("Sent Wake on Lan Signal!")
-	return "Sent Wake on Lan Signal"
+	writeJSON(w, ActionResponse{Action: "Turned off room", Shutdown: result})
 }
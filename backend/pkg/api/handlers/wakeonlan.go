@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"backend/pkg/display"
+	"backend/pkg/serialhandler"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// WakeOnLanTarget is a target as listed by the API
+type WakeOnLanTarget struct {
+	Name       string `json:"name"`
+	MACAddress string `json:"macAddress"`
+}
+
+// GetWakeOnLanTargets lists the Wake-on-LAN targets of the room
+func (h *Handlers) GetWakeOnLanTargets(w http.ResponseWriter, r *http.Request) {
+	targets := []WakeOnLanTarget{}
+	for _, target := range h.wakeOnLanTargets() {
+		targets = append(targets, WakeOnLanTarget{Name: target.Name, MACAddress: target.MACAddress})
+	}
+	writeJSON(w, targets)
+}
+
+// WakeTarget sends the magic packet to one target
+func (h *Handlers) WakeTarget(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["target"]
+	for _, target := range h.wakeOnLanTargets() {
+		if target.Name != name {
+			continue
+		}
+		if err := display.SendMagicPacket(target); err != nil {
+			log.Printf("Waking %s failed: %v", name, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, ActionResponse{Action: fmt.Sprintf("Sent Wake on Lan Signal to %s", name)})
+		return
+	}
+	http.Error(w, fmt.Sprintf("Unknown wake_on_lan target %q", name), http.StatusNotFound)
+}
+
+// wakeOnLanTargets collects the targets from the top level and from every
+// display device, the first one wins when names repeat
+func (h *Handlers) wakeOnLanTargets() []serialhandler.WakeOnLanTarget {
+	var targets []serialhandler.WakeOnLanTarget
+	seen := map[string]bool{}
+	add := func(config serialhandler.Config) {
+		for _, target := range config.WakeOnLanTargets() {
+			if !seen[target.Name] {
+				seen[target.Name] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	add(*h.Config)
+	for _, device := range h.Config.Devices {
+		if device.Type == serialhandler.DeviceDisplay {
+			add(device.Config)
+		}
+	}
+	return targets
+}
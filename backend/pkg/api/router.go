@@ -1,14 +1,38 @@
 package api
 
 import (
+	"backend/internal/calendar"
 	"backend/pkg/api/handlers"
+	"backend/pkg/room"
+	"backend/pkg/scene"
 	"backend/pkg/serialhandler"
 
 	"github.com/gorilla/mux"
 )
 
-func SetupRoutes(router *mux.Router, port *serialhandler.Port) {
-	h := &handlers.Handlers{Port: port}
-	router.HandleFunc("/api/button/{id}", h.HandleButtonClick).Methods("POST")
+func SetupRoutes(router *mux.Router, devices *room.Room, config *serialhandler.Config, meetings *calendar.Service) {
+	scenes := &scene.Runner{Room: devices, Config: config}
+	h := &handlers.Handlers{Room: devices, Config: config, Scenes: scenes, Calendar: meetings}
+	router.HandleFunc("/api/inputs", h.GetInputs).Methods("GET")
+	router.HandleFunc("/api/inputs/{name}/select", h.SelectInput).Methods("POST")
+	router.HandleFunc("/api/power/{state}", h.SetPower).Methods("POST")
+	router.HandleFunc("/api/shutdown", h.Shutdown).Methods("POST")
+	router.HandleFunc("/api/display/input/{input}", h.SelectDisplayInput).Methods("POST")
+	router.HandleFunc("/api/display/volume/{level}", h.SetDisplayVolume).Methods("POST")
+	router.HandleFunc("/api/wake", h.GetWakeOnLanTargets).Methods("GET")
+	router.HandleFunc("/api/wake/{target}", h.WakeTarget).Methods("POST")
+	router.HandleFunc("/api/devices", h.GetDevices).Methods("GET")
+	router.HandleFunc("/api/devices/{device}/inputs", h.GetInputs).Methods("GET")
+	router.HandleFunc("/api/devices/{device}/inputs/{name}/select", h.SelectInput).Methods("POST")
+	router.HandleFunc("/api/devices/{device}/power/{state}", h.SetPower).Methods("POST")
+	router.HandleFunc("/api/devices/{device}/volume/{level}", h.SetDisplayVolume).Methods("POST")
+	router.HandleFunc("/api/devices/{device}/commands/{label}", h.SendCommand).Methods("POST")
+	router.HandleFunc("/api/devices/{device}/state", h.GetDeviceState).Methods("GET")
+	router.HandleFunc("/api/devices/{device}/status", h.GetSwitcherStatus).Methods("GET")
+	router.HandleFunc("/api/scenes", h.GetScenes).Methods("GET")
+	router.HandleFunc("/api/scenes/{name}", h.RunScene).Methods("POST")
+	router.HandleFunc("/api/button/{id}", h.HandleButtonClick).Methods("POST") // Compatibility with older panels
+	router.HandleFunc("/api/switcher/status", h.GetSwitcherStatus).Methods("GET")
+	router.HandleFunc("/api/state", h.GetState).Methods("GET")
 	router.HandleFunc("/api/checkMeetingStatus", h.GetCurrentMeetingStatusFromEnv).Methods("GET")
 }
@@ -0,0 +1,52 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"fmt"
+)
+
+// cecDriver powers the display with HDMI-CEC commands sent by a switcher,
+// e.g. "cec on" on switchers that forward CEC to the connected TV
+type cecDriver struct {
+	port     *serialhandler.Port
+	powerOn  string
+	powerOff string
+}
+
+// NewCECDriver creates the cec driver, it sends its commands on the port of the switcher
+func NewCECDriver(config serialhandler.Config, port *serialhandler.Port) (Driver, error) {
+	if port == nil {
+		return nil, fmt.Errorf("cec display needs a switcher")
+	}
+	return &cecDriver{port: port, powerOn: config.Display.PowerOnCommand, powerOff: config.Display.PowerOffCommand}, nil
+}
+
+func (d *cecDriver) PowerOn() error {
+	if d.powerOn == "" {
+		return fmt.Errorf("power on: %w", ErrUnsupported)
+	}
+	return d.port.Write(d.powerOn)
+}
+
+func (d *cecDriver) PowerOff() error {
+	if d.powerOff == "" {
+		return fmt.Errorf("power off: %w", ErrUnsupported)
+	}
+	return d.port.Write(d.powerOff)
+}
+
+func (d *cecDriver) SelectInput(input string) error {
+	return fmt.Errorf("input selection: %w", ErrUnsupported)
+}
+
+func (d *cecDriver) SetVolume(level int) error {
+	return fmt.Errorf("volume: %w", ErrUnsupported)
+}
+
+// QueryStatus can not know the display state, CEC replies stay inside the switcher
+func (d *cecDriver) QueryStatus() (Status, error) {
+	return Status{Power: serialhandler.PowerStateUnknown}, nil
+}
+
+// Close leaves the port to its switcher
+func (d *cecDriver) Close() {}
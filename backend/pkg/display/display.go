@@ -0,0 +1,73 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Power states reported in Status.Power, on top of the switcher ones
+const (
+	PowerStateWarmingUp   = "warming_up"
+	PowerStateCoolingDown = "cooling_down"
+)
+
+// ErrUnsupported is returned for actions the display driver cannot perform
+var ErrUnsupported = errors.New("not supported by this display")
+
+// ErrUnknownInput is returned for input names the display driver does not know
+var ErrUnknownInput = errors.New("unknown display input")
+
+// Status is what the display reports about itself
+type Status struct {
+	Power string `json:"power"`
+	Input string `json:"input,omitempty"`
+	// Volume is 0-100, nil when the display does not report it
+	Volume *int `json:"volume,omitempty"`
+	// LampHours has one entry per lamp, projectors only
+	LampHours []int `json:"lampHours,omitempty"`
+	// Errors maps a component (fan, lamp, ...) to "warning" or "error"
+	Errors      map[string]string `json:"errors,omitempty"`
+	LastUpdated time.Time         `json:"lastUpdated"`
+}
+
+// Driver controls the TV or projector of the room
+type Driver interface {
+	PowerOn() error
+	PowerOff() error
+	// SelectInput switches the display itself, input is the driver's own input code
+	SelectInput(input string) error
+	// SetVolume sets the volume from 0 to 100
+	SetVolume(level int) error
+	QueryStatus() (Status, error)
+	Close()
+}
+
+// drivers maps the "driver" key of the display block to its constructor
+var drivers = map[string]func(config serialhandler.Config) (Driver, error){
+	"wol":         newWakeOnLanDriver,
+	"pjlink":      newPJLinkDriver,
+	"samsung_mdc": newSamsungDriver,
+	"lg":          newLGDriver,
+}
+
+// New returns the driver selected by config.Display.Driver, Wake-on-LAN by default
+func New(config serialhandler.Config) (Driver, error) {
+	name := config.Display.Driver
+	if name == "" {
+		name = "wol"
+	}
+
+	newDriver, ok := drivers[name]
+	if name == "cec" {
+		return nil, fmt.Errorf("the cec display driver needs a switcher, use NewCECDriver")
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown display driver %q", name)
+	}
+
+	log.Printf("Using display driver: %s", name)
+	return newDriver(config)
+}
@@ -0,0 +1,169 @@
+package display
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// FakeDisplayServer is a local stand-in for a Samsung MDC or LG display on
+// TCP, so those drivers can be run without hardware (tests and --simulate)
+type FakeDisplayServer struct {
+	listener net.Listener
+	protocol string
+
+	mu     sync.Mutex
+	power  byte
+	volume byte
+	input  byte
+}
+
+// NewFakeDisplayServer listens on address for protocol "samsung_mdc" or "lg"
+func NewFakeDisplayServer(protocol, address string) (*FakeDisplayServer, error) {
+	s := &FakeDisplayServer{protocol: protocol, volume: 20}
+	switch protocol {
+	case "samsung_mdc":
+		s.input = mdcInputs["hdmi1"]
+	case "lg":
+		s.input = lgInputs["hdmi1"]
+	default:
+		return nil, fmt.Errorf("no fake display for %q", protocol)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s.listener = listener
+	go s.serve()
+	log.Printf("Fake %s display listening on %s", protocol, listener.Addr())
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on
+func (s *FakeDisplayServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the server
+func (s *FakeDisplayServer) Close() error {
+	return s.listener.Close()
+}
+
+func (s *FakeDisplayServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *FakeDisplayServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var received []byte
+	buffer := make([]byte, 256)
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Minute))
+		n, err := conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		received = append(received, buffer[:n]...)
+
+		for {
+			var request []byte
+			if s.protocol == "samsung_mdc" {
+				length := mdcFrameLength(received)
+				if length == 0 {
+					break
+				}
+				request, received = received[:length], received[length:]
+				conn.Write(s.replyMDC(request))
+			} else {
+				end := bytes.IndexByte(received, '\r')
+				if end < 0 {
+					break
+				}
+				request, received = received[:end], received[end+1:]
+				conn.Write(s.replyLG(string(request)))
+			}
+		}
+	}
+}
+
+// replyMDC answers an MDC frame, request is AA command id length data... checksum
+func (s *FakeDisplayServer) replyMDC(request []byte) []byte {
+	command, id, data := request[1], request[2], request[4:len(request)-1]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ack := byte(mdcAck)
+	var values []byte
+	switch {
+	case len(data) == 0 && command == mdcStatus:
+		values = []byte{s.power, s.volume, 0, s.input, 0x10, 0, 0}
+	case len(data) == 0 && command == mdcPower:
+		values = []byte{s.power}
+	case len(data) == 1 && command == mdcPower:
+		s.power = data[0]
+		values = data
+	case len(data) == 1 && command == mdcVolume && data[0] <= 100:
+		s.volume = data[0]
+		values = data
+	case len(data) == 1 && command == mdcInput && s.power == 1:
+		s.input = data[0]
+		values = data
+	default:
+		ack = mdcNak
+		values = []byte{0x01}
+	}
+
+	frame := []byte{mdcHeader, mdcReply, id, byte(2 + len(values)), ack, command}
+	frame = append(frame, values...)
+	return append(frame, mdcChecksum(frame[1:]))
+}
+
+// replyLG answers "ka 01 01" style commands
+func (s *FakeDisplayServer) replyLG(request string) []byte {
+	var command, id, data string
+	if _, err := fmt.Sscanf(request, "%2s %2s %2s", &command, &id, &data); err != nil || len(command) != 2 {
+		return nil
+	}
+	value, err := strconv.ParseUint(data, 16, 8)
+	if err != nil {
+		return []byte(fmt.Sprintf("%c %s NG%sx", command[1], id, data))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var field *byte
+	switch command {
+	case lgPower:
+		field = &s.power
+	case lgVolume:
+		field = &s.volume
+	case lgInput:
+		field = &s.input
+	}
+
+	ok := field != nil && (command == lgPower || s.power == 1)
+	if command == lgVolume && value != lgQuery && value > 100 {
+		ok = false
+	}
+	if !ok {
+		return []byte(fmt.Sprintf("%c %s NG%sx", command[1], id, data))
+	}
+	if value != lgQuery {
+		*field = byte(value)
+	}
+	return []byte(fmt.Sprintf("%c %s OK%02xx", command[1], id, *field))
+}
@@ -0,0 +1,84 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestDisplayDriversAgainstFake(t *testing.T) {
+	for _, protocol := range []string{"samsung_mdc", "lg"} {
+		t.Run(protocol, func(t *testing.T) {
+			server, err := NewFakeDisplayServer(protocol, "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to start fake display: %v", err)
+			}
+			defer server.Close()
+
+			host, portText, _ := net.SplitHostPort(server.Addr())
+			port, _ := strconv.Atoi(portText)
+			driver, err := New(serialhandler.Config{Display: serialhandler.DisplayConfig{
+				Driver:    protocol,
+				Host:      host,
+				Port:      port,
+				ID:        1,
+				Input:     "hdmi2",
+				TimeoutMs: 2000,
+			}})
+			if err != nil {
+				t.Fatalf("failed to create driver: %v", err)
+			}
+			defer driver.Close()
+
+			status, err := driver.QueryStatus()
+			if err != nil {
+				t.Fatalf("QueryStatus: %v", err)
+			}
+			if status.Power != serialhandler.PowerStateStandby {
+				t.Errorf("power = %q, want standby", status.Power)
+			}
+
+			// The fake only accepts inputs while the display is on
+			if err := driver.SelectInput("hdmi1"); !errors.Is(err, serialhandler.ErrDeviceRejected) {
+				t.Errorf("SelectInput in standby error = %v, want ErrDeviceRejected", err)
+			}
+
+			if err := driver.PowerOn(); err != nil {
+				t.Fatalf("PowerOn: %v", err)
+			}
+			if err := driver.SetVolume(35); err != nil {
+				t.Fatalf("SetVolume: %v", err)
+			}
+			status, err = driver.QueryStatus()
+			if err != nil {
+				t.Fatalf("QueryStatus: %v", err)
+			}
+			if status.Power != serialhandler.PowerStateOn {
+				t.Errorf("power = %q, want on", status.Power)
+			}
+			// PowerOn selects the configured input
+			if status.Input != "hdmi2" {
+				t.Errorf("input = %q, want hdmi2", status.Input)
+			}
+			if status.Volume == nil || *status.Volume != 35 {
+				t.Errorf("volume = %v, want 35", status.Volume)
+			}
+
+			if err := driver.SelectInput("nonsense"); !errors.Is(err, ErrUnknownInput) {
+				t.Errorf("SelectInput(nonsense) error = %v, want ErrUnknownInput", err)
+			}
+			if err := driver.SetVolume(101); err == nil {
+				t.Error("SetVolume(101) succeeded, want an error")
+			}
+
+			if err := driver.PowerOff(); err != nil {
+				t.Fatalf("PowerOff: %v", err)
+			}
+			if status, _ := driver.QueryStatus(); status.Power != serialhandler.PowerStateStandby {
+				t.Errorf("power = %q after PowerOff, want standby", status.Power)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LG commands are two letters, the set ID and the data in hex, e.g. "ka 01 01"
+// to power on set 1. The set answers "a 01 OK01x" or "a 01 NG00x".
+const (
+	lgDefaultPort = 9761
+	lgQuery       = 0xFF
+
+	lgPower  = "ka"
+	lgVolume = "kf"
+	lgInput  = "xb"
+)
+
+var lgReplyPattern = regexp.MustCompile(`([a-z]) ([0-9A-Fa-f]{2}) (OK|NG)([0-9A-Fa-f]*)x`)
+
+// lgInputs maps input names to the data of the "xb" command
+var lgInputs = map[string]byte{
+	"dtv":         0x00,
+	"av":          0x20,
+	"component":   0x40,
+	"rgb":         0x60,
+	"hdmi1":       0x90,
+	"hdmi2":       0x91,
+	"hdmi3":       0x92,
+	"hdmi4":       0x93,
+	"displayport": 0xC0,
+}
+
+// lgDriver controls an LG display over its RS232 protocol, or the same
+// protocol on TCP port 9761
+type lgDriver struct {
+	link  *link
+	id    byte
+	input string
+}
+
+func newLGDriver(config serialhandler.Config) (Driver, error) {
+	link, err := newLink(config.Display, lgDefaultPort)
+	if err != nil {
+		return nil, err
+	}
+	if config.Display.Input != "" {
+		if _, err := lgInputCode(config.Display.Input); err != nil {
+			return nil, err
+		}
+	}
+	return &lgDriver{link: link, id: byte(config.Display.ID), input: config.Display.Input}, nil
+}
+
+// PowerOn powers the display and selects the configured input
+func (d *lgDriver) PowerOn() error {
+	if _, err := d.send(lgPower, 1); err != nil {
+		return err
+	}
+	if d.input != "" {
+		return d.SelectInput(d.input)
+	}
+	return nil
+}
+
+func (d *lgDriver) PowerOff() error {
+	_, err := d.send(lgPower, 0)
+	return err
+}
+
+// SelectInput takes a name from lgInputs or a hex input code like "90"
+func (d *lgDriver) SelectInput(input string) error {
+	code, err := lgInputCode(input)
+	if err != nil {
+		return err
+	}
+	_, err = d.send(lgInput, code)
+	return err
+}
+
+func (d *lgDriver) SetVolume(level int) error {
+	if level < 0 || level > 100 {
+		return fmt.Errorf("volume %d out of range 0-100", level)
+	}
+	_, err := d.send(lgVolume, byte(level))
+	return err
+}
+
+// QueryStatus reads the power state, and the input and volume while the set is on
+func (d *lgDriver) QueryStatus() (Status, error) {
+	status := Status{Power: serialhandler.PowerStateUnknown}
+
+	power, err := d.send(lgPower, lgQuery)
+	if err != nil {
+		return status, err
+	}
+	status.Power = serialhandler.PowerStateStandby
+	if power == 1 {
+		status.Power = serialhandler.PowerStateOn
+
+		if input, err := d.send(lgInput, lgQuery); err == nil {
+			status.Input = lgInputName(input)
+		}
+		if volume, err := d.send(lgVolume, lgQuery); err == nil {
+			level := int(volume)
+			status.Volume = &level
+		}
+	}
+
+	status.LastUpdated = time.Now()
+	return status, nil
+}
+
+func (d *lgDriver) Close() {
+	d.link.close()
+}
+
+// send writes one command and returns the data of the OK reply
+func (d *lgDriver) send(command string, data byte) (byte, error) {
+	request := fmt.Sprintf("%s %02x %02x", command, d.id, data)
+
+	reply, err := d.link.exchange([]byte(request+"\r"), func(received []byte) int {
+		if match := lgReplyPattern.FindIndex(received); match != nil {
+			return match[1]
+		}
+		return 0
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	match := lgReplyPattern.FindStringSubmatch(string(reply))
+	if match[1] != command[1:] || match[3] != "OK" {
+		return 0, &serialhandler.DeviceError{Device: "display", Command: request, Reply: match[0]}
+	}
+	value, err := strconv.ParseUint(match[4], 16, 8)
+	if err != nil {
+		return 0, &serialhandler.DeviceError{Device: "display", Command: request, Reply: match[0]}
+	}
+	return byte(value), nil
+}
+
+func lgInputCode(input string) (byte, error) {
+	if code, ok := lgInputs[strings.ToLower(input)]; ok {
+		return code, nil
+	}
+	code, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(input), "0x"), 16, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q for LG", ErrUnknownInput, input)
+	}
+	return byte(code), nil
+}
+
+func lgInputName(code byte) string {
+	for name, c := range lgInputs {
+		if c == code {
+			return name
+		}
+	}
+	return fmt.Sprintf("%02X", code)
+}
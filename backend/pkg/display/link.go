@@ -0,0 +1,120 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDisplayBaudRate = 9600
+	defaultLinkTimeout     = 3 * time.Second
+	linkReadInterval       = 100 * time.Millisecond
+)
+
+// link is a connection to a display over RS232 or TCP, shared by the drivers
+// with a binary or line protocol. It reconnects on the next exchange after an error.
+type link struct {
+	config  serialhandler.Config
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn serialhandler.Conn
+}
+
+// newLink connects to display.Device over RS232 when it is set, to Host:Port otherwise
+func newLink(display serialhandler.DisplayConfig, defaultPort int) (*link, error) {
+	config := serialhandler.Config{}
+	switch {
+	case display.Device != "":
+		config.Device = display.Device
+		config.BaudRate = display.BaudRate
+		if config.BaudRate == 0 {
+			config.BaudRate = defaultDisplayBaudRate
+		}
+		config.DataBits = 8
+		config.StopBits = 1
+		config.Parity = "none"
+	case display.Host != "":
+		port := display.Port
+		if port == 0 {
+			port = defaultPort
+		}
+		config.Transport = serialhandler.TransportConfig{
+			Type: serialhandler.TransportTCP,
+			Host: display.Host,
+			Port: port,
+		}
+	default:
+		return nil, errors.New("display needs a host or a device")
+	}
+
+	timeout := defaultLinkTimeout
+	if display.TimeoutMs > 0 {
+		timeout = time.Duration(display.TimeoutMs) * time.Millisecond
+	}
+	return &link{config: config, timeout: timeout}, nil
+}
+
+// exchange writes request and reads until complete reports a full reply.
+// complete returns the length of the reply, or 0 while more bytes are needed.
+func (l *link) exchange(request []byte, complete func(received []byte) int) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		conn, name, err := serialhandler.OpenTransport(l.config)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", serialhandler.ErrOffline, err)
+		}
+		log.Printf("Connected to display on %s", name)
+		l.conn = conn
+	}
+
+	if _, err := l.conn.Write(request); err != nil {
+		l.reset()
+		return nil, fmt.Errorf("%w: %v", serialhandler.ErrOffline, err)
+	}
+
+	var received []byte
+	buffer := make([]byte, 256)
+	deadline := time.Now().Add(l.timeout)
+	for time.Now().Before(deadline) {
+		// Serial ports return after their read timeout, sockets need a deadline
+		if conn, ok := l.conn.(net.Conn); ok {
+			conn.SetReadDeadline(time.Now().Add(linkReadInterval))
+		}
+		n, err := l.conn.Read(buffer)
+		received = append(received, buffer[:n]...)
+		if length := complete(received); length > 0 {
+			return received[:length], nil
+		}
+
+		var netErr net.Error
+		if err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+			l.reset()
+			return nil, fmt.Errorf("%w: %v", serialhandler.ErrOffline, err)
+		}
+	}
+
+	// The reply may still arrive, start the next exchange on a clean connection
+	l.reset()
+	return nil, fmt.Errorf("%w: % X", serialhandler.ErrNoAck, request)
+}
+
+func (l *link) reset() {
+	if l.conn != nil {
+		l.conn.Close()
+		l.conn = nil
+	}
+}
+
+func (l *link) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.reset()
+}
@@ -0,0 +1,273 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"bufio"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pjlinkDefaultPort    = 4352
+	pjlinkDefaultTimeout = 5 * time.Second
+)
+
+// pjlinkErrors describes the PJLink error replies
+var pjlinkErrors = map[string]string{
+	"ERR1": "undefined command",
+	"ERR2": "out of parameter",
+	"ERR3": "unavailable time",
+	"ERR4": "projector failure",
+}
+
+// pjlinkAuthFailed is the reply of a DeviceError for a wrong password
+const pjlinkAuthFailed = "authentication failed"
+
+// pjlinkErrorStatus names the six digits of the ERST reply in order
+var pjlinkErrorStatus = []string{"fan", "lamp", "temperature", "cover", "filter", "other"}
+
+// pjlinkDriver controls a projector over PJLink class 1 or 2. Every action
+// opens its own session since projectors drop idle connections after 30 seconds.
+type pjlinkDriver struct {
+	address  string
+	password string
+	input    string
+	timeout  time.Duration
+
+	// mu serializes sessions, most projectors accept only one connection
+	mu    sync.Mutex
+	class int
+}
+
+func newPJLinkDriver(config serialhandler.Config) (Driver, error) {
+	display := config.Display
+	if display.Host == "" {
+		return nil, fmt.Errorf("pjlink display needs a host")
+	}
+
+	port := display.Port
+	if port == 0 {
+		port = pjlinkDefaultPort
+	}
+	timeout := pjlinkDefaultTimeout
+	if display.TimeoutMs > 0 {
+		timeout = time.Duration(display.TimeoutMs) * time.Millisecond
+	}
+
+	return &pjlinkDriver{
+		address:  net.JoinHostPort(display.Host, strconv.Itoa(port)),
+		password: display.Password,
+		input:    display.Input,
+		timeout:  timeout,
+		class:    display.Class,
+	}, nil
+}
+
+// PowerOn powers the projector and selects the configured input. Projectors
+// reject the input while warming up, so that is only logged.
+func (d *pjlinkDriver) PowerOn() error {
+	return d.session(func(s *pjlinkSession) error {
+		if _, err := s.command(1, "POWR", "1"); err != nil {
+			return err
+		}
+		if d.input != "" {
+			if _, err := s.command(d.class, "INPT", d.input); err != nil {
+				log.Printf("PJLink could not select input %s after power on: %v", d.input, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (d *pjlinkDriver) PowerOff() error {
+	return d.session(func(s *pjlinkSession) error {
+		_, err := s.command(1, "POWR", "0")
+		return err
+	})
+}
+
+// SelectInput takes the PJLink input code, e.g. "31" for the first digital input
+func (d *pjlinkDriver) SelectInput(input string) error {
+	return d.session(func(s *pjlinkSession) error {
+		_, err := s.command(d.class, "INPT", input)
+		return err
+	})
+}
+
+// QueryStatus reads the power state, input, lamp hours and error status
+func (d *pjlinkDriver) QueryStatus() (Status, error) {
+	status := Status{Power: serialhandler.PowerStateUnknown}
+
+	err := d.session(func(s *pjlinkSession) error {
+		power, err := s.command(1, "POWR", "?")
+		if err != nil {
+			return err
+		}
+		switch power {
+		case "0":
+			status.Power = serialhandler.PowerStateStandby
+		case "1":
+			status.Power = serialhandler.PowerStateOn
+		case "2":
+			status.Power = PowerStateCoolingDown
+		case "3":
+			status.Power = PowerStateWarmingUp
+		}
+
+		// The input and lamps can not be read while the projector is in standby
+		if input, err := s.command(d.class, "INPT", "?"); err == nil {
+			status.Input = input
+		}
+		if lamps, err := s.command(1, "LAMP", "?"); err == nil {
+			// "hours on/off" pairs, one per lamp
+			fields := strings.Fields(lamps)
+			for i := 0; i+1 < len(fields); i += 2 {
+				if hours, err := strconv.Atoi(fields[i]); err == nil {
+					status.LampHours = append(status.LampHours, hours)
+				}
+			}
+		}
+
+		errorStatus, err := s.command(1, "ERST", "?")
+		if err != nil {
+			return err
+		}
+		for i, component := range pjlinkErrorStatus {
+			if i >= len(errorStatus) {
+				break
+			}
+			switch errorStatus[i] {
+			case '1':
+				status.Errors = ensureMap(status.Errors)
+				status.Errors[component] = "warning"
+			case '2':
+				status.Errors = ensureMap(status.Errors)
+				status.Errors[component] = "error"
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return status, err
+	}
+
+	status.LastUpdated = time.Now()
+	return status, nil
+}
+
+func (d *pjlinkDriver) SetVolume(level int) error {
+	return fmt.Errorf("volume: %w", ErrUnsupported)
+}
+
+func (d *pjlinkDriver) Close() {}
+
+// session connects, authenticates and runs fn
+func (d *pjlinkDriver) session(fn func(s *pjlinkSession) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	conn, err := net.DialTimeout("tcp", d.address, d.timeout)
+	if err != nil {
+		return fmt.Errorf("%w: %v", serialhandler.ErrOffline, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(d.timeout))
+
+	s := &pjlinkSession{conn: conn, reader: bufio.NewReader(conn)}
+
+	// "PJLINK 0" without authentication, "PJLINK 1 <random>" with it
+	greeting, err := s.readLine()
+	if err != nil {
+		return fmt.Errorf("%w: no PJLink greeting: %v", serialhandler.ErrNoAck, err)
+	}
+	fields := strings.Fields(greeting)
+	switch {
+	case len(fields) == 2 && fields[0] == "PJLINK" && fields[1] == "0":
+	case len(fields) == 3 && fields[0] == "PJLINK" && fields[1] == "1":
+		sum := md5.Sum([]byte(fields[2] + d.password))
+		s.digest = hex.EncodeToString(sum[:])
+	default:
+		return &serialhandler.DeviceError{Device: "display", Command: "connect", Reply: greeting}
+	}
+
+	if d.class == 0 {
+		class, err := s.command(1, "CLSS", "?")
+		if isPJLinkAuthError(err) {
+			return err
+		}
+		d.class = 1
+		if err == nil && class == "2" {
+			d.class = 2
+		}
+	}
+
+	return fn(s)
+}
+
+// pjlinkSession is one authenticated connection
+type pjlinkSession struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	// digest is sent in front of the first command only
+	digest string
+}
+
+// command sends "%<class><body> <param>" and returns the value after "="
+func (s *pjlinkSession) command(class int, body, param string) (string, error) {
+	request := fmt.Sprintf("%%%d%s %s", class, body, param)
+	line := request + "\r"
+	if s.digest != "" {
+		line = s.digest + line
+		s.digest = ""
+	}
+	if _, err := s.conn.Write([]byte(line)); err != nil {
+		return "", fmt.Errorf("%w: %v", serialhandler.ErrOffline, err)
+	}
+
+	reply, err := s.readLine()
+	if err != nil {
+		return "", fmt.Errorf("%w: %q: %v", serialhandler.ErrNoAck, request, err)
+	}
+	if reply == "PJLINK ERRA" {
+		return "", &serialhandler.DeviceError{Device: "display", Command: request, Reply: pjlinkAuthFailed}
+	}
+
+	prefix := fmt.Sprintf("%%%d%s=", class, body)
+	value, ok := strings.CutPrefix(reply, prefix)
+	if !ok {
+		return "", &serialhandler.DeviceError{Device: "display", Command: request, Reply: reply}
+	}
+	if description, isError := pjlinkErrors[value]; isError {
+		return "", &serialhandler.DeviceError{Device: "display", Command: request, Reply: fmt.Sprintf("%s (%s)", value, description)}
+	}
+	return value, nil
+}
+
+// isPJLinkAuthError reports whether the projector refused the password
+func isPJLinkAuthError(err error) bool {
+	var deviceErr *serialhandler.DeviceError
+	return errors.As(err, &deviceErr) && deviceErr.Reply == pjlinkAuthFailed
+}
+
+func (s *pjlinkSession) readLine() (string, error) {
+	line, err := s.reader.ReadString('\r')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func ensureMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
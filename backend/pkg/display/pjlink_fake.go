@@ -0,0 +1,182 @@
+package display
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakePJLinkServer is a local stand-in for a PJLink class 2 projector, so the
+// pjlink driver can be run without hardware (tests and --simulate)
+type FakePJLinkServer struct {
+	listener net.Listener
+	password string
+
+	mu        sync.Mutex
+	power     string
+	input     string
+	lampHours int
+	errors    string
+	// WarmUp is how long the projector stays in warm-up/cool-down
+	WarmUp time.Duration
+}
+
+// NewFakePJLinkServer listens on address ("127.0.0.1:0" for any free port).
+// With a password it requires the MD5 authentication like a real projector.
+func NewFakePJLinkServer(address, password string) (*FakePJLinkServer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FakePJLinkServer{
+		listener:  listener,
+		password:  password,
+		power:     "0",
+		input:     "31",
+		lampHours: 1200,
+		errors:    "000000",
+	}
+	go s.serve()
+	log.Printf("Fake PJLink projector listening on %s", listener.Addr())
+	return s, nil
+}
+
+// Addr returns the host:port the server listens on
+func (s *FakePJLinkServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// SetErrorStatus sets the six digit ERST reply, e.g. "010000" for a lamp warning
+func (s *FakePJLinkServer) SetErrorStatus(status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = status
+}
+
+// Close stops the server
+func (s *FakePJLinkServer) Close() error {
+	return s.listener.Close()
+}
+
+func (s *FakePJLinkServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *FakePJLinkServer) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	digest := ""
+	if s.password == "" {
+		fmt.Fprint(conn, "PJLINK 0\r")
+	} else {
+		random := make([]byte, 4)
+		rand.Read(random)
+		sum := md5.Sum([]byte(hex.EncodeToString(random) + s.password))
+		digest = hex.EncodeToString(sum[:])
+		fmt.Fprintf(conn, "PJLINK 1 %s\r", hex.EncodeToString(random))
+	}
+
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\r')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+
+		if digest != "" {
+			if !strings.HasPrefix(line, digest) {
+				fmt.Fprint(conn, "PJLINK ERRA\r")
+				return
+			}
+			line = strings.TrimPrefix(line, digest)
+			digest = ""
+		}
+
+		fmt.Fprintf(conn, "%s\r", s.reply(line))
+	}
+}
+
+// reply answers one "%<class><body> <param>" command
+func (s *FakePJLinkServer) reply(line string) string {
+	if len(line) < 7 || line[0] != '%' {
+		return "PJLINK ERR1"
+	}
+	header, param := line[:6], strings.TrimSpace(line[6:])
+	answer := func(value string) string { return header + "=" + value }
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch body := header[2:]; {
+	case body == "POWR" && param == "?":
+		return answer(s.power)
+	case body == "POWR" && (param == "0" || param == "1"):
+		s.setPower(param)
+		return answer("OK")
+	case body == "INPT" && param == "?":
+		if s.power != "1" {
+			return answer("ERR3")
+		}
+		return answer(s.input)
+	case body == "INPT":
+		if s.power != "1" {
+			return answer("ERR3")
+		}
+		if len(param) != 2 || param[0] < '1' || param[0] > '6' {
+			return answer("ERR2")
+		}
+		s.input = param
+		return answer("OK")
+	case body == "LAMP" && param == "?":
+		on := "0"
+		if s.power == "1" {
+			on = "1"
+		}
+		return answer(fmt.Sprintf("%d %s", s.lampHours, on))
+	case body == "ERST" && param == "?":
+		return answer(s.errors)
+	case body == "CLSS" && param == "?":
+		return answer("2")
+	case body == "NAME" && param == "?":
+		return answer("Fake projector")
+	default:
+		return answer("ERR1")
+	}
+}
+
+// setPower goes through warm-up or cool-down, must be called with s.mu held
+func (s *FakePJLinkServer) setPower(power string) {
+	if s.WarmUp <= 0 {
+		s.power = power
+		return
+	}
+
+	transition := "3"
+	if power == "0" {
+		transition = "2"
+	}
+	s.power = transition
+	time.AfterFunc(s.WarmUp, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.power == transition {
+			s.power = power
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakePJLink starts a fake projector and a pjlink driver logging in with password
+func newFakePJLink(t *testing.T, serverPassword, password string) (Driver, *FakePJLinkServer) {
+	t.Helper()
+	server, err := NewFakePJLinkServer("127.0.0.1:0", serverPassword)
+	if err != nil {
+		t.Fatalf("failed to start fake projector: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	host, portText, _ := net.SplitHostPort(server.Addr())
+	port, _ := strconv.Atoi(portText)
+	driver, err := New(serialhandler.Config{Display: serialhandler.DisplayConfig{
+		Driver:    "pjlink",
+		Host:      host,
+		Port:      port,
+		Password:  password,
+		Input:     "32",
+		TimeoutMs: 2000,
+	}})
+	if err != nil {
+		t.Fatalf("failed to create driver: %v", err)
+	}
+	t.Cleanup(driver.Close)
+	return driver, server
+}
+
+func TestPJLinkAuthenticated(t *testing.T) {
+	driver, server := newFakePJLink(t, "secret", "secret")
+
+	status, err := driver.QueryStatus()
+	if err != nil {
+		t.Fatalf("QueryStatus: %v", err)
+	}
+	if status.Power != serialhandler.PowerStateStandby {
+		t.Errorf("power = %q, want standby", status.Power)
+	}
+
+	if err := driver.PowerOn(); err != nil {
+		t.Fatalf("PowerOn: %v", err)
+	}
+	server.SetErrorStatus("010000")
+	status, err = driver.QueryStatus()
+	if err != nil {
+		t.Fatalf("QueryStatus: %v", err)
+	}
+	if status.Power != serialhandler.PowerStateOn {
+		t.Errorf("power = %q, want on", status.Power)
+	}
+	// PowerOn selects the configured input
+	if status.Input != "32" {
+		t.Errorf("input = %q, want 32", status.Input)
+	}
+	if len(status.LampHours) != 1 || status.LampHours[0] != 1200 {
+		t.Errorf("lamp hours = %v, want [1200]", status.LampHours)
+	}
+	if status.Errors["lamp"] != "warning" || len(status.Errors) != 1 {
+		t.Errorf("errors = %v, want a lamp warning", status.Errors)
+	}
+
+	if err := driver.SelectInput("11"); err != nil {
+		t.Fatalf("SelectInput: %v", err)
+	}
+	if status, _ := driver.QueryStatus(); status.Input != "11" {
+		t.Errorf("input = %q, want 11", status.Input)
+	}
+
+	if err := driver.PowerOff(); err != nil {
+		t.Fatalf("PowerOff: %v", err)
+	}
+	if status, _ := driver.QueryStatus(); status.Power != serialhandler.PowerStateStandby {
+		t.Errorf("power = %q after PowerOff, want standby", status.Power)
+	}
+}
+
+func TestPJLinkWrongPassword(t *testing.T) {
+	driver, _ := newFakePJLink(t, "secret", "wrong")
+
+	err := driver.PowerOn()
+	if !errors.Is(err, serialhandler.ErrDeviceRejected) {
+		t.Fatalf("PowerOn error = %v, want ErrDeviceRejected", err)
+	}
+	if !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("error = %q, want authentication failed", err)
+	}
+}
+
+func TestPJLinkWithoutPassword(t *testing.T) {
+	driver, _ := newFakePJLink(t, "", "")
+
+	if err := driver.PowerOn(); err != nil {
+		t.Fatalf("PowerOn: %v", err)
+	}
+	status, err := driver.QueryStatus()
+	if err != nil {
+		t.Fatalf("QueryStatus: %v", err)
+	}
+	if status.Power != serialhandler.PowerStateOn {
+		t.Errorf("power = %q, want on", status.Power)
+	}
+}
+
+func TestPJLinkRejectedInput(t *testing.T) {
+	driver, _ := newFakePJLink(t, "secret", "secret")
+
+	// Inputs can not be selected in standby
+	err := driver.SelectInput("31")
+	if !errors.Is(err, serialhandler.ErrDeviceRejected) || !strings.Contains(err.Error(), "ERR3") {
+		t.Errorf("SelectInput in standby error = %v, want ERR3", err)
+	}
+
+	driver.PowerOn()
+	err = driver.SelectInput("99")
+	if !errors.Is(err, serialhandler.ErrDeviceRejected) || !strings.Contains(err.Error(), "ERR2") {
+		t.Errorf("SelectInput(99) error = %v, want ERR2", err)
+	}
+}
+
+func TestPJLinkWarmUp(t *testing.T) {
+	driver, server := newFakePJLink(t, "", "")
+	server.WarmUp = 200 * time.Millisecond
+
+	if err := driver.PowerOn(); err != nil {
+		t.Fatalf("PowerOn: %v", err)
+	}
+	if status, _ := driver.QueryStatus(); status.Power != PowerStateWarmingUp {
+		t.Errorf("power = %q right after PowerOn, want warming_up", status.Power)
+	}
+	time.Sleep(400 * time.Millisecond)
+	if status, _ := driver.QueryStatus(); status.Power != serialhandler.PowerStateOn {
+		t.Errorf("power = %q after warm-up, want on", status.Power)
+	}
+}
@@ -0,0 +1,158 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"fmt"
+	"log"
+	"net"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Probes for ReadyConfig.Probe
+const (
+	ProbePower = "power"
+	ProbeTCP   = "tcp"
+	ProbePing  = "ping"
+)
+
+const (
+	defaultRetryInterval = 10 * time.Second
+	defaultReadyTimeout  = 60 * time.Second
+	probeInterval        = time.Second
+	probeTimeout         = time.Second
+)
+
+// WakeResult reports how powering on a display went
+type WakeResult struct {
+	Ready    bool   `json:"ready"`
+	Attempts int    `json:"attempts"`
+	Waited   string `json:"waited"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Wake powers on the display and waits until the probe in ready confirms it is
+// up, sending the power on command again every retry interval
+func Wake(driver Driver, ready serialhandler.ReadyConfig, displayHost string) WakeResult {
+	started := time.Now()
+	result := WakeResult{}
+	finish := func(err error) WakeResult {
+		result.Waited = time.Since(started).Round(time.Millisecond).String()
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return result
+	}
+
+	probe, err := newProbe(driver, ready, displayHost)
+	if err != nil {
+		return finish(err)
+	}
+
+	retryInterval := defaultRetryInterval
+	if ready.RetryIntervalMs > 0 {
+		retryInterval = time.Duration(ready.RetryIntervalMs) * time.Millisecond
+	}
+	timeout := defaultReadyTimeout
+	if ready.TimeoutMs > 0 {
+		timeout = time.Duration(ready.TimeoutMs) * time.Millisecond
+	}
+	deadline := started.Add(timeout)
+
+	var lastErr error
+	for result.Attempts <= ready.Retries {
+		result.Attempts++
+		if err := driver.PowerOn(); err != nil {
+			lastErr = err
+			log.Printf("Power on attempt %d failed: %v", result.Attempts, err)
+		} else if probe == nil {
+			result.Ready = true
+			return finish(nil)
+		}
+		if probe == nil {
+			continue
+		}
+
+		// Probe until the next attempt is due
+		next := time.Now().Add(retryInterval)
+		if result.Attempts > ready.Retries || next.After(deadline) {
+			next = deadline
+		}
+		for {
+			err := probe()
+			if err == nil {
+				result.Ready = true
+				return finish(nil)
+			}
+			lastErr = err
+
+			remaining := time.Until(next)
+			if remaining <= 0 {
+				break
+			}
+			time.Sleep(min(probeInterval, remaining))
+		}
+		if !time.Now().Before(deadline) {
+			break
+		}
+	}
+
+	return finish(fmt.Errorf("display not ready after %d attempts: %w", result.Attempts, lastErr))
+}
+
+// newProbe returns a check that fails until the display is up, nil without a probe
+func newProbe(driver Driver, ready serialhandler.ReadyConfig, displayHost string) (func() error, error) {
+	host := ready.Host
+	if host == "" {
+		host = displayHost
+	}
+
+	switch ready.Probe {
+	case "":
+		return nil, nil
+	case ProbePower:
+		return func() error {
+			status, err := driver.QueryStatus()
+			if err != nil {
+				return err
+			}
+			if status.Power != serialhandler.PowerStateOn {
+				return fmt.Errorf("display reports power %s", status.Power)
+			}
+			return nil
+		}, nil
+	case ProbeTCP:
+		if host == "" || ready.Port == 0 {
+			return nil, fmt.Errorf("tcp probe needs a host and a port")
+		}
+		address := net.JoinHostPort(host, strconv.Itoa(ready.Port))
+		return func() error {
+			conn, err := net.DialTimeout("tcp", address, probeTimeout)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}, nil
+	case ProbePing:
+		if host == "" {
+			return nil, fmt.Errorf("ping probe needs a host")
+		}
+		return func() error { return ping(host) }, nil
+	default:
+		return nil, fmt.Errorf("unknown ready probe %q", ready.Probe)
+	}
+}
+
+// ping sends one ICMP echo with the system ping command, raw sockets need privileges
+func ping(host string) error {
+	args := []string{"-c", "1", "-W", "1", host}
+	if runtime.GOOS == "windows" {
+		args = []string{"-n", "1", "-w", "1000", host}
+	}
+	if err := exec.Command("ping", args...).Run(); err != nil {
+		return fmt.Errorf("no reply to ping from %s", host)
+	}
+	return nil
+}
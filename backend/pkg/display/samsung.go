@@ -0,0 +1,192 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Samsung MDC frames are 0xAA, command, display ID, data length, data and a
+// checksum of everything after the header. Replies use command 0xFF with 'A'
+// (ack) or 'N' (nak), the command they answer and its data.
+const (
+	mdcHeader      = 0xAA
+	mdcReply       = 0xFF
+	mdcAck         = 'A'
+	mdcNak         = 'N'
+	mdcDefaultPort = 1515
+
+	mdcStatus = 0x00
+	mdcPower  = 0x11
+	mdcVolume = 0x12
+	mdcInput  = 0x14
+)
+
+// mdcInputs maps input names to MDC source codes
+var mdcInputs = map[string]byte{
+	"pc":          0x14,
+	"dvi":         0x18,
+	"av":          0x0C,
+	"component":   0x08,
+	"hdmi1":       0x21,
+	"hdmi1_pc":    0x22,
+	"hdmi2":       0x23,
+	"hdmi2_pc":    0x24,
+	"displayport": 0x25,
+	"hdmi3":       0x31,
+	"hdmi4":       0x33,
+	"magicinfo":   0x60,
+}
+
+// samsungDriver controls a Samsung display over MDC
+type samsungDriver struct {
+	link  *link
+	id    byte
+	input string
+}
+
+func newSamsungDriver(config serialhandler.Config) (Driver, error) {
+	link, err := newLink(config.Display, mdcDefaultPort)
+	if err != nil {
+		return nil, err
+	}
+	if config.Display.Input != "" {
+		if _, err := mdcInputCode(config.Display.Input); err != nil {
+			return nil, err
+		}
+	}
+	return &samsungDriver{link: link, id: byte(config.Display.ID), input: config.Display.Input}, nil
+}
+
+// PowerOn powers the display and selects the configured input
+func (d *samsungDriver) PowerOn() error {
+	if _, err := d.send(mdcPower, 1); err != nil {
+		return err
+	}
+	if d.input != "" {
+		return d.SelectInput(d.input)
+	}
+	return nil
+}
+
+func (d *samsungDriver) PowerOff() error {
+	_, err := d.send(mdcPower, 0)
+	return err
+}
+
+// SelectInput takes a name from mdcInputs or a hex source code like "0x21"
+func (d *samsungDriver) SelectInput(input string) error {
+	code, err := mdcInputCode(input)
+	if err != nil {
+		return err
+	}
+	_, err = d.send(mdcInput, code)
+	return err
+}
+
+func (d *samsungDriver) SetVolume(level int) error {
+	if level < 0 || level > 100 {
+		return fmt.Errorf("volume %d out of range 0-100", level)
+	}
+	_, err := d.send(mdcVolume, byte(level))
+	return err
+}
+
+// QueryStatus reads power, volume, mute and input in one status request
+func (d *samsungDriver) QueryStatus() (Status, error) {
+	status := Status{Power: serialhandler.PowerStateUnknown}
+
+	data, err := d.send(mdcStatus)
+	if err != nil {
+		return status, err
+	}
+	if len(data) < 4 {
+		return status, fmt.Errorf("short MDC status reply % X", data)
+	}
+
+	status.Power = serialhandler.PowerStateStandby
+	if data[0] == 1 {
+		status.Power = serialhandler.PowerStateOn
+	}
+	volume := int(data[1])
+	status.Volume = &volume
+	status.Input = mdcInputName(data[3])
+	status.LastUpdated = time.Now()
+	return status, nil
+}
+
+func (d *samsungDriver) Close() {
+	d.link.close()
+}
+
+// send writes one command and returns the data of its ack
+func (d *samsungDriver) send(command byte, data ...byte) ([]byte, error) {
+	frame := []byte{mdcHeader, command, d.id, byte(len(data))}
+	frame = append(frame, data...)
+	frame = append(frame, mdcChecksum(frame[1:]))
+
+	reply, err := d.link.exchange(frame, mdcFrameLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// AA FF id length ack/nak command data... checksum
+	if reply[1] != mdcReply || len(reply) < 7 || reply[5] != command {
+		return nil, &serialhandler.DeviceError{Device: "display", Command: fmt.Sprintf("% X", frame), Reply: fmt.Sprintf("% X", reply)}
+	}
+	if reply[4] != mdcAck {
+		return nil, &serialhandler.DeviceError{Device: "display", Command: fmt.Sprintf("% X", frame), Reply: fmt.Sprintf("NAK % X", reply[6:len(reply)-1])}
+	}
+	return reply[6 : len(reply)-1], nil
+}
+
+// mdcFrameLength finds a complete frame with a valid checksum, skipping
+// anything before the header
+func mdcFrameLength(received []byte) int {
+	start := 0
+	for start < len(received) && received[start] != mdcHeader {
+		start++
+	}
+	if len(received)-start < 4 {
+		return 0
+	}
+	end := start + 4 + int(received[start+3]) + 1
+	if len(received) < end {
+		return 0
+	}
+	if mdcChecksum(received[start+1:end-1]) != received[end-1] {
+		return 0
+	}
+	copy(received, received[start:end])
+	return end - start
+}
+
+func mdcChecksum(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return sum
+}
+
+func mdcInputCode(input string) (byte, error) {
+	if code, ok := mdcInputs[strings.ToLower(input)]; ok {
+		return code, nil
+	}
+	code, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(input), "0x"), 16, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q for Samsung MDC", ErrUnknownInput, input)
+	}
+	return byte(code), nil
+}
+
+func mdcInputName(code byte) string {
+	for name, c := range mdcInputs {
+		if c == code {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%02X", code)
+}
@@ -0,0 +1,155 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+)
+
+const defaultWakeOnLanPort = 9
+
+// wakeOnLanDriver can only wake the TV with a magic packet, it has no way
+// to turn it off or to know whether it is on
+type wakeOnLanDriver struct {
+	targets []serialhandler.WakeOnLanTarget
+}
+
+func newWakeOnLanDriver(config serialhandler.Config) (Driver, error) {
+	targets := config.WakeOnLanTargets()
+	if len(config.Display.Targets) > 0 {
+		targets = nil
+		for _, name := range config.Display.Targets {
+			target, ok := config.WakeOnLanTarget(name)
+			if !ok {
+				return nil, fmt.Errorf("unknown wake_on_lan target %q", name)
+			}
+			targets = append(targets, target)
+		}
+	}
+	return &wakeOnLanDriver{targets: targets}, nil
+}
+
+// PowerOn sends the magic packet to every target of the display
+func (d *wakeOnLanDriver) PowerOn() error {
+	if len(d.targets) == 0 {
+		return errors.New("no wake_on_lan target or tv_macaddress configured")
+	}
+
+	var errs []error
+	for _, target := range d.targets {
+		if err := SendMagicPacket(target); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *wakeOnLanDriver) PowerOff() error {
+	return fmt.Errorf("power off: %w", ErrUnsupported)
+}
+
+func (d *wakeOnLanDriver) SelectInput(input string) error {
+	return fmt.Errorf("input selection: %w", ErrUnsupported)
+}
+
+func (d *wakeOnLanDriver) QueryStatus() (Status, error) {
+	return Status{Power: serialhandler.PowerStateUnknown}, nil
+}
+
+func (d *wakeOnLanDriver) SetVolume(level int) error {
+	return fmt.Errorf("volume: %w", ErrUnsupported)
+}
+
+func (d *wakeOnLanDriver) Close() {}
+
+// SendMagicPacket wakes one target: 6 bytes 0xFF, the MAC address 16 times
+// and the SecureOn password if there is one
+func SendMagicPacket(target serialhandler.WakeOnLanTarget) error {
+	mac, err := net.ParseMAC(target.MACAddress)
+	if err != nil || len(mac) != 6 {
+		return fmt.Errorf("invalid MAC address %q", target.MACAddress)
+	}
+	password, err := serialhandler.SecureOnPassword(target.Password)
+	if err != nil {
+		return err
+	}
+
+	packet := bytes.Repeat([]byte{0xFF}, 6)
+	packet = append(packet, bytes.Repeat(mac, 16)...)
+	packet = append(packet, password...)
+
+	var local *net.UDPAddr
+	var subnet *net.IPNet
+	if target.Interface != "" {
+		if local, subnet, err = interfaceAddress(target.Interface); err != nil {
+			return err
+		}
+	}
+	broadcast, err := broadcastAddress(target.Broadcast, subnet)
+	if err != nil {
+		return err
+	}
+	port := target.Port
+	if port == 0 {
+		port = defaultWakeOnLanPort
+	}
+
+	remote := &net.UDPAddr{IP: broadcast, Port: port}
+	conn, err := net.DialUDP("udp4", local, remote)
+	if err != nil {
+		return fmt.Errorf("error sending magic packet to %s: %w", remote, err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(packet); err != nil {
+		return fmt.Errorf("error sending magic packet to %s: %w", remote, err)
+	}
+
+	log.Printf("Sent Wake on Lan Signal to %s (%s) via %s", target.Name, mac, remote)
+	return nil
+}
+
+// interfaceAddress returns the first IPv4 address of the interface, to send from, and its subnet
+func interfaceAddress(name string) (*net.UDPAddr, *net.IPNet, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+	addresses, err := iface.Addrs()
+	if err != nil {
+		return nil, nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+	for _, address := range addresses {
+		if ipNet, ok := address.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return &net.UDPAddr{IP: ipNet.IP.To4()}, ipNet, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("interface %s has no IPv4 address", name)
+}
+
+// broadcastAddress resolves an IP, a subnet's directed broadcast, or the
+// broadcast of the interface subnet when nothing is configured
+func broadcastAddress(broadcast string, subnet *net.IPNet) (net.IP, error) {
+	switch {
+	case broadcast != "":
+		if ip := net.ParseIP(broadcast).To4(); ip != nil {
+			return ip, nil
+		}
+		_, configured, err := net.ParseCIDR(broadcast)
+		if err != nil || configured.IP.To4() == nil {
+			return nil, fmt.Errorf("invalid broadcast %q", broadcast)
+		}
+		subnet = configured
+	case subnet == nil:
+		return net.IPv4bcast, nil
+	}
+
+	ip := make(net.IP, 4)
+	network := subnet.IP.To4()
+	for i := range ip {
+		ip[i] = network[i] | ^subnet.Mask[len(subnet.Mask)-4+i]
+	}
+	return ip, nil
+}
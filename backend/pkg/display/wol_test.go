@@ -0,0 +1,97 @@
+package display
+
+import (
+	"backend/pkg/serialhandler"
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSendMagicPacket(t *testing.T) {
+	mac := []byte{0x00, 0x11, 0x22, 0xaa, 0xbb, 0xcc}
+	tests := []struct {
+		name     string
+		password string
+		suffix   []byte
+	}{
+		{"without password", "", nil},
+		{"mac password", "01:02:03:04:05:06", []byte{1, 2, 3, 4, 5, 6}},
+		{"ipv4 password", "192.168.1.7", []byte{192, 168, 1, 7}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			listener, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+
+			err = SendMagicPacket(serialhandler.WakeOnLanTarget{
+				Name:       "tv",
+				MACAddress: "00-11-22-AA-BB-CC",
+				Broadcast:  "127.0.0.1",
+				Port:       listener.LocalAddr().(*net.UDPAddr).Port,
+				Password:   test.password,
+			})
+			if err != nil {
+				t.Fatalf("SendMagicPacket: %v", err)
+			}
+
+			packet := make([]byte, 256)
+			listener.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := listener.ReadFromUDP(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := append(bytes.Repeat([]byte{0xFF}, 6), bytes.Repeat(mac, 16)...)
+			want = append(want, test.suffix...)
+			if !bytes.Equal(packet[:n], want) {
+				t.Errorf("packet of %d bytes = % x, want %d bytes % x", n, packet[:n], len(want), want)
+			}
+		})
+	}
+}
+
+func TestSendMagicPacketRejectsBadTargets(t *testing.T) {
+	for _, target := range []serialhandler.WakeOnLanTarget{
+		{Name: "short", MACAddress: "00:11:22:33:44"},
+		{Name: "garbage", MACAddress: "tv"},
+		{Name: "eui64", MACAddress: "00:11:22:33:44:55:66:77"},
+		{Name: "password", MACAddress: "00:11:22:33:44:55", Password: "secret"},
+		{Name: "broadcast", MACAddress: "00:11:22:33:44:55", Broadcast: "10.0.0"},
+	} {
+		if err := SendMagicPacket(target); err == nil {
+			t.Errorf("%s: sent a magic packet for %+v", target.Name, target)
+		}
+	}
+}
+
+func TestBroadcastAddress(t *testing.T) {
+	_, eth1, _ := net.ParseCIDR("10.1.2.3/16")
+	tests := []struct {
+		broadcast string
+		subnet    *net.IPNet
+		want      string
+	}{
+		{"", nil, "255.255.255.255"},
+		{"192.168.1.255", nil, "192.168.1.255"},
+		{"192.168.10.0/24", nil, "192.168.10.255"},
+		{"192.168.10.77/24", nil, "192.168.10.255"},
+		{"172.16.0.0/12", nil, "172.31.255.255"},
+		{"", eth1, "10.1.255.255"},
+		{"192.168.10.0/24", eth1, "192.168.10.255"},
+	}
+	for _, test := range tests {
+		ip, err := broadcastAddress(test.broadcast, test.subnet)
+		if err != nil || ip.String() != test.want {
+			t.Errorf("broadcastAddress(%q, %v) = %v, %v, want %s", test.broadcast, test.subnet, ip, err, test.want)
+		}
+	}
+
+	for _, broadcast := range []string{"broadcast", "10.0.0", "fe80::/64"} {
+		if ip, err := broadcastAddress(broadcast, nil); err == nil {
+			t.Errorf("broadcastAddress(%q) = %v, want an error", broadcast, ip)
+		}
+	}
+}
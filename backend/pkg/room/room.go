@@ -0,0 +1,142 @@
+package room
+
+import (
+	"backend/pkg/display"
+	"backend/pkg/serialhandler"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// ErrUnknownDevice is returned for device names that are not in config.json
+var ErrUnknownDevice = errors.New("unknown device")
+
+// Switcher is a device on a Port, e.g. an HDMI matrix or an audio DSP
+type Switcher struct {
+	Name   string
+	Config serialhandler.Config
+	Port   *serialhandler.Port
+	Driver serialhandler.SwitcherDriver
+}
+
+// Display is a TV or projector
+type Display struct {
+	Name   string
+	Config serialhandler.DisplayConfig
+	Driver display.Driver
+}
+
+// Wake powers on the display and waits until it is ready, as set in its "ready" block
+func (d *Display) Wake() display.WakeResult {
+	result := display.Wake(d.Driver, d.Config.Ready, d.Config.Host)
+	log.Printf("Woke display %s: ready %t after %d attempts", d.Name, result.Ready, result.Attempts)
+	return result
+}
+
+// WakeAll wakes every display at the same time and returns the results by display name
+func (r *Room) WakeAll() map[string]display.WakeResult {
+	results := make(map[string]display.WakeResult, len(r.Displays))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, d := range r.Displays {
+		wg.Add(1)
+		go func(d *Display) {
+			defer wg.Done()
+			result := d.Wake()
+			mu.Lock()
+			results[d.Name] = result
+			mu.Unlock()
+		}(d)
+	}
+	wg.Wait()
+	return results
+}
+
+// Room holds the devices from config.json in the order they are declared
+type Room struct {
+	Switchers []*Switcher
+	Displays  []*Display
+}
+
+// Open connects to every device of the room. opener picks how each switcher is reached.
+func Open(config *serialhandler.Config, opener func(device serialhandler.DeviceConfig) serialhandler.Opener) (*Room, error) {
+	room := &Room{}
+	devices := config.DeviceList()
+
+	// Switchers first, cec displays send their commands through one
+	for _, device := range devices {
+		if device.Type == serialhandler.DeviceDisplay {
+			continue
+		}
+		// The supervisor keeps reconnecting if the device is not available yet
+		port := serialhandler.NewPortWithOpener(device.Config, opener(device))
+		driver, err := serialhandler.NewDriver(device.Config, port)
+		if err != nil {
+			port.Close()
+			room.Close()
+			return nil, fmt.Errorf("switcher %s: %w", device.Name, err)
+		}
+		room.Switchers = append(room.Switchers, &Switcher{Name: device.Name, Config: device.Config, Port: port, Driver: driver})
+		log.Printf("Added %s %s", device.Type, device.Name)
+	}
+
+	for _, device := range devices {
+		if device.Type != serialhandler.DeviceDisplay {
+			continue
+		}
+		var driver display.Driver
+		var err error
+		if device.Display.Driver == "cec" {
+			var switcher *Switcher
+			if switcher, err = room.Switcher(device.Display.Switcher); err == nil {
+				driver, err = display.NewCECDriver(device.Config, switcher.Port)
+			}
+		} else {
+			driver, err = display.New(device.Config)
+		}
+		if err != nil {
+			room.Close()
+			return nil, fmt.Errorf("display %s: %w", device.Name, err)
+		}
+		room.Displays = append(room.Displays, &Display{Name: device.Name, Config: device.Display, Driver: driver})
+		log.Printf("Added %s %s", device.Type, device.Name)
+	}
+	return room, nil
+}
+
+// Switcher returns the named switcher, the first one for an empty name
+func (r *Room) Switcher(name string) (*Switcher, error) {
+	for _, switcher := range r.Switchers {
+		if name == "" || switcher.Name == name {
+			return switcher, nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: the room has no switcher", ErrUnknownDevice)
+	}
+	return nil, fmt.Errorf("%w: no switcher named %q", ErrUnknownDevice, name)
+}
+
+// Display returns the named display, the first one for an empty name
+func (r *Room) Display(name string) (*Display, error) {
+	for _, display := range r.Displays {
+		if name == "" || display.Name == name {
+			return display, nil
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: the room has no display", ErrUnknownDevice)
+	}
+	return nil, fmt.Errorf("%w: no display named %q", ErrUnknownDevice, name)
+}
+
+// Close disconnects every device
+func (r *Room) Close() {
+	for _, switcher := range r.Switchers {
+		switcher.Driver.Close()
+	}
+	for _, display := range r.Displays {
+		display.Driver.Close()
+	}
+}
@@ -0,0 +1,81 @@
+package room
+
+import (
+	"backend/pkg/display"
+	"backend/pkg/serialhandler"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Device statuses of a shutdown, the same words as scene steps
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// DeviceResult reports how turning off one device went
+type DeviceResult struct {
+	Device string `json:"device"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ShutdownResult reports a whole room shutdown
+type ShutdownResult struct {
+	Success bool           `json:"success"`
+	Devices []DeviceResult `json:"devices"`
+}
+
+// Shutdown turns off the devices in the order of steps. A device that fails
+// does not stop the others, the returned error joins all failures.
+func (r *Room) Shutdown(steps []serialhandler.ShutdownStep) (*ShutdownResult, error) {
+	log.Println("Shutting down the room")
+	result := &ShutdownResult{Success: true}
+	var errs []error
+
+	record := func(name string, err error) {
+		device := DeviceResult{Device: name, Status: StatusOK}
+		switch {
+		case errors.Is(err, display.ErrUnsupported), errors.Is(err, serialhandler.ErrUnsupported):
+			log.Printf("Skipping %s: %v", name, err)
+			device.Status = StatusSkipped
+		case err != nil:
+			log.Printf("Turning off %s failed: %v", name, err)
+			device.Status = StatusFailed
+			device.Error = err.Error()
+			result.Success = false
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		result.Devices = append(result.Devices, device)
+	}
+
+	for i, step := range steps {
+		switch step.Device {
+		case serialhandler.ShutdownDisplays:
+			for _, d := range r.Displays {
+				record(d.Name, d.Driver.PowerOff())
+			}
+		case serialhandler.ShutdownSwitchers:
+			for _, switcher := range r.Switchers {
+				record(switcher.Name, switcher.Driver.PowerOff())
+			}
+		default:
+			if d, err := r.Display(step.Device); err == nil {
+				record(d.Name, d.Driver.PowerOff())
+			} else if switcher, err := r.Switcher(step.Device); err == nil {
+				record(switcher.Name, switcher.Driver.PowerOff())
+			} else {
+				record(step.Device, err)
+			}
+		}
+
+		if step.DelayMs > 0 && i < len(steps)-1 {
+			time.Sleep(time.Duration(step.DelayMs) * time.Millisecond)
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
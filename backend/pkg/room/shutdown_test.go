@@ -0,0 +1,73 @@
+package room
+
+import (
+	"backend/pkg/display"
+	"backend/pkg/serialhandler"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// powerSwitcher is a switcher whose PowerOff returns err
+type powerSwitcher struct {
+	serialhandler.SwitcherDriver
+	err error
+}
+
+func (s powerSwitcher) PowerOff() error { return s.err }
+
+// powerDisplay is a display whose PowerOff returns err
+type powerDisplay struct {
+	display.Driver
+	err error
+}
+
+func (d powerDisplay) PowerOff() error { return d.err }
+
+func TestShutdown(t *testing.T) {
+	r := &Room{
+		Switchers: []*Switcher{
+			{Name: "matrix", Driver: powerSwitcher{}},
+			{Name: "dsp", Driver: powerSwitcher{err: fmt.Errorf("no command configured for %q: %w", "turn_off", serialhandler.ErrUnsupported)}},
+		},
+		Displays: []*Display{
+			{Name: "tv", Driver: powerDisplay{}},
+			{Name: "projector", Driver: powerDisplay{err: display.ErrUnsupported}},
+		},
+	}
+
+	result, err := r.Shutdown([]serialhandler.ShutdownStep{{Device: serialhandler.ShutdownDisplays}, {Device: serialhandler.ShutdownSwitchers}})
+	if err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	want := []DeviceResult{
+		{Device: "tv", Status: StatusOK},
+		{Device: "projector", Status: StatusSkipped},
+		{Device: "matrix", Status: StatusOK},
+		{Device: "dsp", Status: StatusSkipped},
+	}
+	if !result.Success || !reflect.DeepEqual(result.Devices, want) {
+		t.Errorf("result = %+v, want success with %+v", result, want)
+	}
+}
+
+func TestShutdownContinuesAfterFailure(t *testing.T) {
+	r := &Room{
+		Switchers: []*Switcher{{Name: "matrix", Driver: powerSwitcher{err: serialhandler.ErrOffline}}},
+		Displays:  []*Display{{Name: "tv", Driver: powerDisplay{}}},
+	}
+
+	result, err := r.Shutdown([]serialhandler.ShutdownStep{{Device: "matrix"}, {Device: "tv"}, {Device: "speakers"}})
+	if !errors.Is(err, serialhandler.ErrOffline) || !errors.Is(err, ErrUnknownDevice) {
+		t.Errorf("error = %v, want the offline switcher and the unknown device", err)
+	}
+	statuses := map[string]string{}
+	for _, device := range result.Devices {
+		statuses[device.Device] = device.Status
+	}
+	want := map[string]string{"matrix": StatusFailed, "tv": StatusOK, "speakers": StatusFailed}
+	if result.Success || !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+}
@@ -0,0 +1,209 @@
+package scene
+
+import (
+	"backend/pkg/display"
+	"backend/pkg/room"
+	"backend/pkg/serialhandler"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrUnknownScene is returned for scene names that are not in config.json
+var ErrUnknownScene = errors.New("unknown scene")
+
+// ErrBusy is returned while another scene is still running
+var ErrBusy = errors.New("another scene is running")
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	waitPollInterval   = 1 * time.Second
+)
+
+// Runner executes the scenes from config.json one at a time
+type Runner struct {
+	Room   *room.Room
+	Config *serialhandler.Config
+
+	mu sync.Mutex
+}
+
+// StepResult reports how a single step went
+type StepResult struct {
+	Step     int    `json:"step"`
+	Type     string `json:"type"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Result reports a whole scene run
+type Result struct {
+	Scene   string       `json:"scene"`
+	Success bool         `json:"success"`
+	Steps   []StepResult `json:"steps"`
+}
+
+// Step statuses
+const (
+	StatusOK      = "ok"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+)
+
+// Run executes the named scene. Steps that fail abort the scene unless their
+// on_error is "continue"; the returned error is the one that aborted it.
+func (r *Runner) Run(name string) (*Result, error) {
+	scene, ok := r.Config.Scenes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownScene, name)
+	}
+
+	if !r.mu.TryLock() {
+		return nil, ErrBusy
+	}
+	defer r.mu.Unlock()
+
+	log.Printf("Running scene %s", name)
+	result := &Result{Scene: name, Success: true}
+	var abortErr error
+
+	for i, step := range scene.Steps {
+		stepResult := StepResult{Step: i + 1, Type: step.Type}
+		if abortErr != nil {
+			stepResult.Status = StatusSkipped
+			result.Steps = append(result.Steps, stepResult)
+			continue
+		}
+
+		started := time.Now()
+		err := r.runStep(step)
+		stepResult.Duration = time.Since(started).Round(time.Millisecond).String()
+
+		if err != nil {
+			log.Printf("Scene %s step %d (%s) failed: %v", name, i+1, step.Type, err)
+			stepResult.Status = StatusFailed
+			stepResult.Error = err.Error()
+			result.Success = false
+			if step.OnError != serialhandler.OnErrorContinue {
+				abortErr = err
+			}
+		} else {
+			stepResult.Status = StatusOK
+		}
+		result.Steps = append(result.Steps, stepResult)
+	}
+
+	log.Printf("Scene %s finished, success: %t", name, result.Success)
+	return result, abortErr
+}
+
+func (r *Runner) runStep(step serialhandler.SceneStep) error {
+	switch step.Type {
+	case serialhandler.StepWakeOnLan:
+		return r.wakeDisplays(step.Device)
+	case serialhandler.StepDelay:
+		time.Sleep(time.Duration(step.DelayMs) * time.Millisecond)
+		return nil
+	}
+
+	// Input and power steps can also go to a display
+	if tv, err := r.Room.Display(step.Device); err == nil && step.Device != "" {
+		switch step.Type {
+		case serialhandler.StepSelectInput:
+			return tv.Driver.SelectInput(step.Input)
+		case serialhandler.StepPower:
+			if step.Power == "on" {
+				return tv.Driver.PowerOn()
+			}
+			return tv.Driver.PowerOff()
+		default:
+			return fmt.Errorf("%s step can not go to display %s", step.Type, tv.Name)
+		}
+	}
+
+	switcher, err := r.Room.Switcher(step.Device)
+	if err != nil {
+		return err
+	}
+
+	switch step.Type {
+	case serialhandler.StepCommand:
+		return switcher.Port.Write(step.Command)
+	case serialhandler.StepSelectInput:
+		input, ok := switcher.Config.InputByName(step.Input)
+		if !ok {
+			return fmt.Errorf("unknown input %q", step.Input)
+		}
+		return switcher.Driver.SelectInput(input.ID)
+	case serialhandler.StepPower:
+		if step.Power == "on" {
+			return switcher.Driver.PowerOn()
+		}
+		return switcher.Driver.PowerOff()
+	case serialhandler.StepWaitForState:
+		return r.waitForState(switcher, step)
+	default:
+		return fmt.Errorf("unknown step type %q", step.Type)
+	}
+}
+
+// wakeDisplays powers on the named display, or every display of the room, and
+// waits until they are ready
+func (r *Runner) wakeDisplays(name string) error {
+	results := map[string]display.WakeResult{}
+	if name != "" {
+		d, err := r.Room.Display(name)
+		if err != nil {
+			return err
+		}
+		results[name] = d.Wake()
+	} else {
+		results = r.Room.WakeAll()
+	}
+
+	var errs []error
+	for name, result := range results {
+		if !result.Ready {
+			errs = append(errs, fmt.Errorf("%s: %s", name, result.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// waitForState polls the switcher until it reports the wanted power state and input
+func (r *Runner) waitForState(switcher *room.Switcher, step serialhandler.SceneStep) error {
+	wantInput := 0
+	if step.Input != "" {
+		input, ok := switcher.Config.InputByName(step.Input)
+		if !ok {
+			return fmt.Errorf("unknown input %q", step.Input)
+		}
+		wantInput = input.ID
+	}
+
+	timeout := defaultWaitTimeout
+	if step.TimeoutMs > 0 {
+		timeout = time.Duration(step.TimeoutMs) * time.Millisecond
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		state, err := switcher.Driver.QueryState()
+		if err == nil &&
+			(step.Power == "" || state.Power == step.Power) &&
+			(wantInput == 0 || state.Input == wantInput) {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("state not reached within %s: %w", timeout, err)
+			}
+			return fmt.Errorf("state not reached within %s, switcher reports input %d, power %s", timeout, state.Input, state.Power)
+		}
+		time.Sleep(waitPollInterval)
+	}
+}
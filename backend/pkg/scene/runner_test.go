@@ -0,0 +1,201 @@
+package scene
+
+import (
+	"backend/pkg/display"
+	"backend/pkg/room"
+	"backend/pkg/serialhandler"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSwitcher records the inputs it is switched to and fails the ones in fail
+type fakeSwitcher struct {
+	mu     sync.Mutex
+	inputs []int
+	fail   map[int]bool
+	state  serialhandler.State
+	// block holds SelectInput until it is closed, started tells it got there
+	block   chan struct{}
+	started chan struct{}
+}
+
+func (s *fakeSwitcher) SelectInput(input int) error {
+	if s.block != nil {
+		s.started <- struct{}{}
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inputs = append(s.inputs, input)
+	if s.fail[input] {
+		return errors.New("command incorrect")
+	}
+	return nil
+}
+
+func (s *fakeSwitcher) PowerOn() error  { return nil }
+func (s *fakeSwitcher) PowerOff() error { return nil }
+func (s *fakeSwitcher) Close()          {}
+
+func (s *fakeSwitcher) QueryState() (serialhandler.State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state, nil
+}
+
+func (s *fakeSwitcher) selected() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.inputs...)
+}
+
+// fakeDisplay counts the power on commands
+type fakeDisplay struct {
+	mu       sync.Mutex
+	powerOns int
+}
+
+func (d *fakeDisplay) PowerOn() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.powerOns++
+	return nil
+}
+
+func (d *fakeDisplay) PowerOff() error                { return nil }
+func (d *fakeDisplay) SelectInput(input string) error { return nil }
+func (d *fakeDisplay) SetVolume(level int) error      { return nil }
+func (d *fakeDisplay) Close()                         {}
+func (d *fakeDisplay) QueryStatus() (display.Status, error) {
+	return display.Status{Power: serialhandler.PowerStateOn}, nil
+}
+
+func newRunner(switcher *fakeSwitcher, tv *fakeDisplay, steps ...serialhandler.SceneStep) *Runner {
+	config := serialhandler.Config{Inputs: []serialhandler.InputConfig{
+		{ID: 1, Name: "wireless"},
+		{ID: 2, Name: "room_pc"},
+		{ID: 3, Name: "camera"},
+	}}
+	return &Runner{
+		Room: &room.Room{
+			Switchers: []*room.Switcher{{Name: "switcher", Config: config, Driver: switcher}},
+			Displays:  []*room.Display{{Name: "tv", Driver: tv}},
+		},
+		Config: &serialhandler.Config{Scenes: map[string]serialhandler.SceneConfig{
+			"presentation": {Steps: steps},
+		}},
+	}
+}
+
+func selectInput(name, onError string) serialhandler.SceneStep {
+	return serialhandler.SceneStep{Type: serialhandler.StepSelectInput, Input: name, OnError: onError}
+}
+
+func statuses(result *Result) string {
+	var statuses []string
+	for _, step := range result.Steps {
+		statuses = append(statuses, step.Status)
+	}
+	return strings.Join(statuses, ",")
+}
+
+func TestRunAbortsAndSkipsTheRest(t *testing.T) {
+	switcher := &fakeSwitcher{fail: map[int]bool{2: true}}
+	tv := &fakeDisplay{}
+	runner := newRunner(switcher, tv,
+		selectInput("wireless", ""),
+		selectInput("room_pc", serialhandler.OnErrorAbort),
+		selectInput("camera", ""),
+		serialhandler.SceneStep{Type: serialhandler.StepWakeOnLan},
+	)
+
+	result, err := runner.Run("presentation")
+	if err == nil || !strings.Contains(err.Error(), "command incorrect") {
+		t.Errorf("Run error = %v, want the error of step 2", err)
+	}
+	if result.Success || statuses(result) != "ok,failed,skipped,skipped" {
+		t.Errorf("result = %+v, want steps ok,failed,skipped,skipped", result)
+	}
+	if result.Steps[1].Error == "" || result.Steps[2].Duration != "" {
+		t.Errorf("steps = %+v, want the error on the failed step and no duration on skipped ones", result.Steps)
+	}
+	if inputs := switcher.selected(); len(inputs) != 2 || tv.powerOns != 0 {
+		t.Errorf("inputs = %v with %d power on commands, want [1 2] and none after the abort", inputs, tv.powerOns)
+	}
+}
+
+func TestRunContinuesAfterError(t *testing.T) {
+	switcher := &fakeSwitcher{fail: map[int]bool{2: true}}
+	tv := &fakeDisplay{}
+	runner := newRunner(switcher, tv,
+		selectInput("room_pc", serialhandler.OnErrorContinue),
+		selectInput("camera", ""),
+		serialhandler.SceneStep{Type: serialhandler.StepWakeOnLan, Device: "tv"},
+	)
+
+	result, err := runner.Run("presentation")
+	if err != nil {
+		t.Errorf("Run error = %v, want nil since the failed step continues", err)
+	}
+	if result.Success || statuses(result) != "failed,ok,ok" {
+		t.Errorf("result = %+v, want unsuccessful with steps failed,ok,ok", result)
+	}
+	if inputs := switcher.selected(); len(inputs) != 2 || inputs[1] != 3 || tv.powerOns != 1 {
+		t.Errorf("inputs = %v with %d power on commands, want [2 3] and 1", inputs, tv.powerOns)
+	}
+}
+
+func TestRunIsBusyWhileAnotherSceneRuns(t *testing.T) {
+	switcher := &fakeSwitcher{block: make(chan struct{}), started: make(chan struct{})}
+	runner := newRunner(switcher, &fakeDisplay{}, selectInput("wireless", ""))
+
+	done := make(chan error)
+	go func() {
+		_, err := runner.Run("presentation")
+		done <- err
+	}()
+	<-switcher.started
+
+	if result, err := runner.Run("presentation"); !errors.Is(err, ErrBusy) || result != nil {
+		t.Errorf("second Run = %+v, %v, want ErrBusy", result, err)
+	}
+	close(switcher.block)
+	if err := <-done; err != nil {
+		t.Errorf("first Run: %v", err)
+	}
+
+	switcher.block = nil
+	if _, err := runner.Run("presentation"); err != nil {
+		t.Errorf("Run after the first finished: %v", err)
+	}
+	if _, err := runner.Run("movie"); !errors.Is(err, ErrUnknownScene) {
+		t.Errorf("Run of a missing scene = %v, want ErrUnknownScene", err)
+	}
+}
+
+func TestWaitForStateTimeout(t *testing.T) {
+	switcher := &fakeSwitcher{state: serialhandler.State{Input: 1, Power: serialhandler.PowerStateStandby}}
+	runner := newRunner(switcher, &fakeDisplay{},
+		serialhandler.SceneStep{Type: serialhandler.StepWaitForState, Power: serialhandler.PowerStateOn, Input: "camera", TimeoutMs: 10},
+	)
+
+	started := time.Now()
+	result, err := runner.Run("presentation")
+	if err == nil || !strings.Contains(err.Error(), "input 1, power standby") {
+		t.Errorf("Run error = %v, want the state the switcher reports", err)
+	}
+	if result.Success || statuses(result) != "failed" {
+		t.Errorf("result = %+v, want the wait step failed", result)
+	}
+	if elapsed := time.Since(started); elapsed > 3*waitPollInterval {
+		t.Errorf("wait_for_state gave up after %v", elapsed)
+	}
+
+	switcher.state = serialhandler.State{Input: 3, Power: serialhandler.PowerStateOn}
+	if _, err := runner.Run("presentation"); err != nil {
+		t.Errorf("Run with the state reached: %v", err)
+	}
+}
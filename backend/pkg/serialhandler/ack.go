@@ -0,0 +1,185 @@
+package serialhandler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNoAck is returned when the switcher does not acknowledge a command in time
+var ErrNoAck = errors.New("no acknowledgment from switcher")
+
+// ErrDeviceRejected is returned when the reply matches the error pattern
+var ErrDeviceRejected = errors.New("switcher rejected command")
+
+const (
+	defaultAckTimeout      = 5 * time.Second
+	defaultTerminator      = "\n"
+	defaultWriteTerminator = "\r\n"
+	// noTerminator disables the write terminator, for protocols like Extron SIS
+	noTerminator = "none"
+)
+
+// AckConfig describes how the reply to a command is recognised. Empty fields fall
+// back to the next level: per-command config, then the driver's protocol rules,
+// then the "ack" block.
+type AckConfig struct {
+	SuccessPattern string `json:"success_pattern"`
+	ErrorPattern   string `json:"error_pattern"`
+	Terminator     string `json:"terminator"`
+	TimeoutMs      int    `json:"timeout_ms"`
+	// WriteTerminator is appended to every text command, "\r\n" by default or "none"
+	WriteTerminator string `json:"write_terminator"`
+	// Frame reads binary frames instead of lines terminated by Terminator
+	Frame *FrameConfig `json:"frame"`
+}
+
+// DeviceError is the reply of a rejected command, it unwraps to ErrDeviceRejected
+type DeviceError struct {
+	// Device names who rejected the command, "switcher" when empty
+	Device  string
+	Command string
+	Reply   string
+}
+
+func (e *DeviceError) Error() string {
+	device := e.Device
+	if device == "" {
+		device = "switcher"
+	}
+	return fmt.Sprintf("%s rejected %q: %s", device, e.Command, e.Reply)
+}
+
+func (e *DeviceError) Unwrap() error {
+	return ErrDeviceRejected
+}
+
+// merge returns a copy of a with the fields set in override replaced
+func (a AckConfig) merge(override AckConfig) AckConfig {
+	if override.SuccessPattern != "" {
+		a.SuccessPattern = override.SuccessPattern
+	}
+	if override.ErrorPattern != "" {
+		a.ErrorPattern = override.ErrorPattern
+	}
+	if override.Terminator != "" {
+		a.Terminator = override.Terminator
+	}
+	if override.TimeoutMs != 0 {
+		a.TimeoutMs = override.TimeoutMs
+	}
+	if override.WriteTerminator != "" {
+		a.WriteTerminator = override.WriteTerminator
+	}
+	if override.Frame != nil {
+		a.Frame = override.Frame
+	}
+	return a
+}
+
+// ackMatcher is a compiled AckConfig
+type ackMatcher struct {
+	success         *regexp.Regexp
+	failure         *regexp.Regexp
+	terminator      string
+	writeTerminator string
+	frame           *frameParser
+	timeout         time.Duration
+}
+
+func (a AckConfig) compile() (*ackMatcher, error) {
+	m := &ackMatcher{terminator: a.Terminator, timeout: time.Duration(a.TimeoutMs) * time.Millisecond}
+	if m.terminator == "" {
+		m.terminator = defaultTerminator
+	}
+	if m.timeout <= 0 {
+		m.timeout = defaultAckTimeout
+	}
+	switch a.WriteTerminator {
+	case "":
+		m.writeTerminator = defaultWriteTerminator
+	case noTerminator:
+		m.writeTerminator = ""
+	default:
+		m.writeTerminator = a.WriteTerminator
+	}
+
+	var err error
+	if m.frame, err = a.Frame.compile(); err != nil {
+		return nil, err
+	}
+	if a.SuccessPattern != "" {
+		if m.success, err = regexp.Compile(a.SuccessPattern); err != nil {
+			return nil, fmt.Errorf("invalid success_pattern %q: %w", a.SuccessPattern, err)
+		}
+	}
+	if a.ErrorPattern != "" {
+		if m.failure, err = regexp.Compile(a.ErrorPattern); err != nil {
+			return nil, fmt.Errorf("invalid error_pattern %q: %w", a.ErrorPattern, err)
+		}
+	}
+	return m, nil
+}
+
+// match classifies a reply line. Without a success pattern every line that is not an error counts.
+func (m *ackMatcher) match(line string) (done bool, rejected bool) {
+	if m.failure != nil && m.failure.MatchString(line) {
+		return true, true
+	}
+	if m.success == nil || m.success.MatchString(line) {
+		return true, false
+	}
+	return false, false
+}
+
+// split cuts the complete replies off data, frames or lines
+func (m *ackMatcher) split(data string) ([]string, string) {
+	if m.frame != nil {
+		return m.frame.split(data)
+	}
+	return splitLines(data, m.terminator)
+}
+
+// ackFor resolves the rules for a command: the "ack" block, then the driver
+// rules from driverAcks and the defaults passed with the command, which keep a generic
+// pattern from hiding protocol errors. command_acks can override them per
+// command using either the raw command, its label from labeled_commands or the input name
+func (p *Port) ackFor(command string, defaults AckConfig) (*ackMatcher, error) {
+	p.mu.Lock()
+	ack := p.Config.Ack.merge(p.driverAck).merge(defaults)
+	p.mu.Unlock()
+
+	if override, ok := p.Config.CommandAcks[command]; ok {
+		return ack.merge(override).compile()
+	}
+	for label, labeled := range p.Config.LabeledCommands {
+		if labeled == command {
+			return ack.merge(p.Config.CommandAcks[label]).compile()
+		}
+	}
+	for _, input := range p.Config.InputList() {
+		if input.Command == command {
+			return ack.merge(p.Config.CommandAcks[input.Name]).compile()
+		}
+	}
+	return ack.compile()
+}
+
+// splitLines cuts every complete line off data and returns them with the remainder
+func splitLines(data, terminator string) ([]string, string) {
+	var lines []string
+	for {
+		index := strings.Index(data, terminator)
+		if index == -1 {
+			return lines, data
+		}
+
+		line := strings.Trim(data[:index], "\r\n")
+		data = data[index+len(terminator):]
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package serialhandler
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAckMatch(t *testing.T) {
+	matcher, err := AckConfig{SuccessPattern: `(?i)command ok$`, ErrorPattern: `(?i)^command incorrect`}.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line     string
+		done     bool
+		rejected bool
+	}{
+		{"sw i01 Command OK", true, false},
+		{"Command incorrect", true, true},
+		{"Input: port 01 Output: ON", false, false},
+	}
+	for _, test := range tests {
+		done, rejected := matcher.match(test.line)
+		if done != test.done || rejected != test.rejected {
+			t.Errorf("match(%q) = %t, %t, want %t, %t", test.line, done, rejected, test.done, test.rejected)
+		}
+	}
+
+	// Without a success pattern every line that is not an error acknowledges the command
+	matcher, _ = AckConfig{ErrorPattern: `^ERR`}.compile()
+	if done, rejected := matcher.match("anything"); !done || rejected {
+		t.Errorf("match without success pattern = %t, %t, want true, false", done, rejected)
+	}
+}
+
+func TestAckCompileDefaults(t *testing.T) {
+	matcher, err := AckConfig{WriteTerminator: noTerminator}.compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matcher.terminator != defaultTerminator || matcher.timeout != defaultAckTimeout || matcher.writeTerminator != "" {
+		t.Errorf("compile() = terminator %q timeout %s write terminator %q", matcher.terminator, matcher.timeout, matcher.writeTerminator)
+	}
+	if _, err := (AckConfig{ErrorPattern: "("}).compile(); err == nil {
+		t.Error("compile accepted an invalid error_pattern")
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	lines, rest := splitLines("one\r\n\r\ntwo\r\nthr", "\n")
+	if !reflect.DeepEqual(lines, []string{"one", "two"}) || rest != "thr" {
+		t.Errorf("splitLines = %q, %q", lines, rest)
+	}
+}
+
+func TestAckForPrecedence(t *testing.T) {
+	p := &Port{Config: Config{
+		Ack:             AckConfig{SuccessPattern: "generic", ErrorPattern: "generic", TimeoutMs: 3000},
+		LabeledCommands: map[string]string{"turn_off": "standby on"},
+		CommandAcks:     map[string]AckConfig{"turn_off": {SuccessPattern: "standby"}},
+	}}
+	p.driverAck = AckConfig{ErrorPattern: "^E\\d\\d$", Terminator: "\r\n"}
+
+	matcher, err := p.ackFor("1!", AckConfig{SuccessPattern: "^In1 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matcher.success.String() != "^In1 " || matcher.failure.String() != `^E\d\d$` || matcher.timeout != 3*time.Second {
+		t.Errorf("driver command: success %s failure %s timeout %s", matcher.success, matcher.failure, matcher.timeout)
+	}
+
+	// command_acks still overrides the driver, looked up by the label of the command
+	matcher, _ = p.ackFor("standby on", AckConfig{SuccessPattern: "^Vmt1$"})
+	if matcher.success.String() != "standby" {
+		t.Errorf("command_acks override: success %s", matcher.success)
+	}
+}
+
+func TestSendWithAck(t *testing.T) {
+	config := Config{
+		LabeledCommands: map[string]string{"turn_on": "standby off"},
+		Ack:             AckConfig{SuccessPattern: "(?i)command ok", ErrorPattern: "(?i)command incorrect", TimeoutMs: 300},
+	}
+	simulator := NewSimulator(config, SimulatorOptions{})
+	port := NewPortWithOpener(config, simulator.Open)
+	defer port.Close()
+
+	if reply, err := port.Send("standby off"); err != nil || reply != "standby off Command OK" {
+		t.Errorf("Send(standby off) = %q, %v", reply, err)
+	}
+
+	_, err := port.Send("bogus")
+	var deviceErr *DeviceError
+	if !errors.As(err, &deviceErr) || !errors.Is(err, ErrDeviceRejected) || deviceErr.Reply != "Command incorrect" {
+		t.Errorf("Send(bogus) = %v, want a DeviceError", err)
+	}
+
+	simulator.SetOptions(SimulatorOptions{DropRate: 1})
+	if _, err := port.Send("standby off"); !errors.Is(err, ErrNoAck) {
+		t.Errorf("Send without reply = %v, want ErrNoAck", err)
+	}
+}
@@ -0,0 +1,248 @@
+package serialhandler
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// hexPrefix marks a command as bytes rather than text, e.g.
+// "hex:AA 11 01 01 01 {sum:1}". Binary commands get no write terminator.
+const hexPrefix = "hex:"
+
+// Checksums for {name} and {name:offset} in hex commands and for FrameConfig
+const (
+	ChecksumSum        = "sum"
+	ChecksumXOR        = "xor"
+	ChecksumCRC16      = "crc16"
+	ChecksumCRC16CCITT = "crc16_ccitt"
+)
+
+// FrameConfig splits binary replies into frames instead of lines. Every frame
+// is matched against the patterns as upper case hex, e.g. "AA FF 01 03 41 11 01 57".
+type FrameConfig struct {
+	// Header is the hex the frame starts with, anything before it is skipped
+	Header string `json:"header"`
+	// Length is a fixed frame length, otherwise the byte at LengthOffset plus
+	// LengthAdjust is the length of the whole frame
+	Length       int `json:"length"`
+	LengthOffset int `json:"length_offset"`
+	LengthAdjust int `json:"length_adjust"`
+	// Checksum at the end of the frame over the bytes from ChecksumFrom, frames
+	// with a wrong checksum are dropped
+	Checksum     string `json:"checksum"`
+	ChecksumFrom int    `json:"checksum_from"`
+}
+
+// frameParser is a compiled FrameConfig
+type frameParser struct {
+	FrameConfig
+	header []byte
+}
+
+func (f *FrameConfig) compile() (*frameParser, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	header, err := hex.DecodeString(strings.Join(strings.Fields(f.Header), ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid frame header %q: %w", f.Header, err)
+	}
+	if f.Length <= 0 && f.LengthOffset <= 0 {
+		return nil, fmt.Errorf("frame needs a length or a length_offset")
+	}
+	if f.Checksum != "" && checksumSize(f.Checksum) == 0 {
+		return nil, fmt.Errorf("unknown frame checksum %q", f.Checksum)
+	}
+	return &frameParser{FrameConfig: *f, header: header}, nil
+}
+
+// split cuts every complete frame off data and returns them as hex with the remainder
+func (f *frameParser) split(data string) ([]string, string) {
+	var frames []string
+	buffer := []byte(data)
+	for {
+		start := 0
+		if len(f.header) > 0 {
+			start = strings.Index(string(buffer), string(f.header))
+			if start == -1 {
+				// Keep what could be the beginning of a header
+				keep := len(f.header) - 1
+				if len(buffer) < keep {
+					keep = len(buffer)
+				}
+				return frames, string(buffer[len(buffer)-keep:])
+			}
+		}
+		buffer = buffer[start:]
+
+		length := f.Length
+		if length <= 0 {
+			if len(buffer) <= f.LengthOffset {
+				return frames, string(buffer)
+			}
+			length = int(buffer[f.LengthOffset]) + f.LengthAdjust
+		}
+		if length <= 0 {
+			buffer = buffer[1:]
+			continue
+		}
+		if len(buffer) < length {
+			return frames, string(buffer)
+		}
+
+		frame := buffer[:length]
+		if f.Checksum != "" && !f.checksumValid(frame) {
+			log.Printf("Dropping frame with a bad checksum: % X", frame)
+			buffer = buffer[1:]
+			continue
+		}
+		frames = append(frames, fmt.Sprintf("% X", frame))
+		buffer = buffer[length:]
+	}
+}
+
+func (f *frameParser) checksumValid(frame []byte) bool {
+	size := checksumSize(f.Checksum)
+	if f.ChecksumFrom > len(frame)-size {
+		return false
+	}
+	expected := checksum(f.Checksum, frame[f.ChecksumFrom:len(frame)-size])
+	return string(expected) == string(frame[len(frame)-size:])
+}
+
+// encodeCommand returns the bytes to write for a command and whether it is binary
+func encodeCommand(command string) ([]byte, bool, error) {
+	template, ok := strings.CutPrefix(command, hexPrefix)
+	if !ok {
+		return []byte(command), false, nil
+	}
+
+	var data []byte
+	for _, token := range strings.Fields(template) {
+		if strings.HasPrefix(token, "{") && strings.HasSuffix(token, "}") {
+			name, offset, err := parsePlaceholder(token)
+			if err != nil {
+				return nil, true, err
+			}
+			if offset > len(data) {
+				return nil, true, fmt.Errorf("checksum %s starts after the end of %q", token, command)
+			}
+			data = append(data, checksum(name, data[offset:])...)
+			continue
+		}
+
+		decoded, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(token), "0x"))
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid hex %q in %q", token, command)
+		}
+		data = append(data, decoded...)
+	}
+	return data, true, nil
+}
+
+// parsePlaceholder reads "{crc16}" or "{sum:1}", the offset is where the checksum starts
+func parsePlaceholder(token string) (string, int, error) {
+	name, offsetText, hasOffset := strings.Cut(strings.Trim(token, "{}"), ":")
+	if checksumSize(name) == 0 {
+		return "", 0, fmt.Errorf("unknown checksum %s", token)
+	}
+	if !hasOffset {
+		return name, 0, nil
+	}
+	offset, err := strconv.Atoi(offsetText)
+	if err != nil || offset < 0 {
+		return "", 0, fmt.Errorf("invalid checksum offset in %s", token)
+	}
+	return name, offset, nil
+}
+
+func checksumSize(name string) int {
+	switch name {
+	case ChecksumSum, ChecksumXOR:
+		return 1
+	case ChecksumCRC16, ChecksumCRC16CCITT:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// checksum computes the named checksum. crc16 is CRC-16/MODBUS (low byte
+// first), crc16_ccitt is CRC-16/CCITT-FALSE (high byte first).
+func checksum(name string, data []byte) []byte {
+	switch name {
+	case ChecksumSum:
+		var sum byte
+		for _, b := range data {
+			sum += b
+		}
+		return []byte{sum}
+	case ChecksumXOR:
+		var sum byte
+		for _, b := range data {
+			sum ^= b
+		}
+		return []byte{sum}
+	case ChecksumCRC16:
+		crc := uint16(0xFFFF)
+		for _, b := range data {
+			crc ^= uint16(b)
+			for i := 0; i < 8; i++ {
+				if crc&1 != 0 {
+					crc = crc>>1 ^ 0xA001
+				} else {
+					crc >>= 1
+				}
+			}
+		}
+		return []byte{byte(crc), byte(crc >> 8)}
+	case ChecksumCRC16CCITT:
+		crc := uint16(0xFFFF)
+		for _, b := range data {
+			crc ^= uint16(b) << 8
+			for i := 0; i < 8; i++ {
+				if crc&0x8000 != 0 {
+					crc = crc<<1 ^ 0x1021
+				} else {
+					crc <<= 1
+				}
+			}
+		}
+		return []byte{byte(crc >> 8), byte(crc)}
+	default:
+		return nil
+	}
+}
+
+// validateCommands encodes every configured command so bad hex is reported at startup
+func (c *Config) validateCommands() error {
+	commands := map[string]string{}
+	for label, command := range c.LabeledCommands {
+		commands["labeled_commands["+label+"]"] = command
+	}
+	for _, input := range c.Inputs {
+		commands["inputs["+input.Name+"]"] = input.Command
+	}
+	for i, command := range c.StartupCommands {
+		commands[fmt.Sprintf("startup_commands[%d]", i)] = command
+	}
+	for name, scene := range c.Scenes {
+		for i, step := range scene.Steps {
+			commands[fmt.Sprintf("scenes[%s] step %d", name, i+1)] = step.Command
+		}
+	}
+	for i, command := range c.StateQuery.Commands {
+		commands[fmt.Sprintf("state_query.commands[%d]", i)] = command
+	}
+
+	for where, command := range commands {
+		if _, _, err := encodeCommand(command); err != nil {
+			return fmt.Errorf("%s: %w", where, err)
+		}
+	}
+	return nil
+}
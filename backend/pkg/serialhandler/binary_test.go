@@ -0,0 +1,126 @@
+package serialhandler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	// The check values of the CRC catalogue are computed over "123456789"
+	check := []byte("123456789")
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{ChecksumSum, []byte{0x11, 0x01, 0x01, 0x01}, "14"},
+		{ChecksumSum, []byte{0xFF, 0x02}, "01"},
+		{ChecksumXOR, []byte{0x01, 0x02, 0x04, 0x0F}, "08"},
+		{ChecksumCRC16, check, "37 4B"},
+		{ChecksumCRC16CCITT, check, "29 B1"},
+	}
+	for _, test := range tests {
+		if got := fmt.Sprintf("% X", checksum(test.name, test.data)); got != test.want {
+			t.Errorf("checksum(%s, % X) = %s, want %s", test.name, test.data, got, test.want)
+		}
+	}
+}
+
+func TestEncodeCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+		binary  bool
+	}{
+		{"w1*1!", "77 31 2A 31 21", false},
+		{"hex:AA 11 01 01 01 {sum:1}", "AA 11 01 01 01 14", true},
+		{"hex:0x01 0x02 {xor}", "01 02 03", true},
+		{"hex:AA11 0101", "AA 11 01 01", true},
+		{"hex:01 03 00 00 00 01 {crc16}", "01 03 00 00 00 01 84 0A", true},
+	}
+	for _, test := range tests {
+		data, binary, err := encodeCommand(test.command)
+		if err != nil {
+			t.Errorf("encodeCommand(%q): %v", test.command, err)
+			continue
+		}
+		if got := fmt.Sprintf("% X", data); got != test.want || binary != test.binary {
+			t.Errorf("encodeCommand(%q) = %s, %v, want %s, %v", test.command, got, binary, test.want, test.binary)
+		}
+	}
+
+	for _, command := range []string{"hex:AA ZZ", "hex:AA {md5}", "hex:AA {sum:5}", "hex:AA {sum:x}"} {
+		if _, _, err := encodeCommand(command); err == nil {
+			t.Errorf("encodeCommand(%q) succeeded, want an error", command)
+		}
+	}
+}
+
+func TestFrameSplit(t *testing.T) {
+	// Samsung MDC style: header AA, the data length at offset 3, sum from offset 1
+	parser, err := (&FrameConfig{Header: "AA", LengthOffset: 3, LengthAdjust: 5, Checksum: ChecksumSum, ChecksumFrom: 1}).compile()
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	good, _, _ := encodeCommand("hex:AA FF 01 03 41 11 01 {sum:1}")
+	bad := []byte{0xAA, 0xFF, 0x01, 0x03, 0x41, 0x11, 0x01, 0x00}
+	data := "\x00\x13" + string(bad) + string(good) + string(good[:4])
+
+	frames, rest := parser.split(data)
+	if want := []string{"AA FF 01 03 41 11 01 56"}; !reflect.DeepEqual(frames, want) {
+		t.Errorf("frames = %q, want %q", frames, want)
+	}
+	if rest != string(good[:4]) {
+		t.Errorf("rest = % X, want the incomplete frame % X", rest, good[:4])
+	}
+
+	// The rest completes with the next read
+	frames, rest = parser.split(rest + string(good[4:]))
+	if len(frames) != 1 || rest != "" {
+		t.Errorf("split of the completed frame = %q, % X", frames, rest)
+	}
+
+	// Only a possible start of the header is kept from garbage
+	if frames, rest := parser.split("\x01\x02\x03"); len(frames) != 0 || rest != "" {
+		t.Errorf("split of garbage = %q, % X", frames, rest)
+	}
+}
+
+func TestFrameSplitFixedLength(t *testing.T) {
+	parser, err := (&FrameConfig{Header: "02 80", Length: 4, Checksum: ChecksumXOR}).compile()
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+
+	frames, rest := parser.split("\x02\x80\x01\x83\x02\x80\x02\x80\x02")
+	if want := []string{"02 80 01 83", "02 80 02 80"}; !reflect.DeepEqual(frames, want) {
+		t.Errorf("frames = %q, want %q", frames, want)
+	}
+	if rest != "\x02" {
+		t.Errorf("rest = % X, want the first header byte", rest)
+	}
+}
+
+func TestFrameConfigCompileErrors(t *testing.T) {
+	configs := map[string]FrameConfig{
+		"bad header":   {Header: "XY", Length: 4},
+		"no length":    {Header: "AA"},
+		"bad checksum": {Header: "AA", Length: 4, Checksum: "md5"},
+	}
+	for name, config := range configs {
+		if _, err := config.compile(); err == nil {
+			t.Errorf("%s: compile succeeded, want an error", name)
+		}
+	}
+}
+
+func TestValidateCommandsReportsBadHex(t *testing.T) {
+	config := Config{LabeledCommands: map[string]string{"turn_on": "hex:AA GG"}}
+	err := config.validateCommands()
+	if err == nil || !strings.Contains(err.Error(), "labeled_commands[turn_on]") {
+		t.Errorf("validateCommands error = %v, want it to name labeled_commands[turn_on]", err)
+	}
+}
@@ -0,0 +1,20 @@
+package serialhandler
+
+// CalendarConfig selects the calendar system the room's meetings are read
+// from, Microsoft Graph by default
+type CalendarConfig struct {
+	// Provider is the calendar backend, "graph" if empty
+	Provider string `json:"provider"`
+	// URL is the feed for ics and the calendar collection for caldav, for
+	// graph and google it overrides the API endpoint, e.g. to point it at a
+	// test server
+	URL string `json:"url"`
+	// Path is a local .ics file, instead of URL
+	Path string `json:"path"`
+	// CredentialsFile is the service account key file for google
+	CredentialsFile string `json:"credentials_file"`
+	// Subject is the Workspace user the google service account acts as, if it uses domain-wide delegation
+	Subject string `json:"subject"`
+	// TokenURL overrides the token endpoint of the service account key, e.g. to use a fake one
+	TokenURL string `json:"token_url"`
+}
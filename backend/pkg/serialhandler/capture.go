@@ -0,0 +1,145 @@
+package serialhandler
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// Capture directions
+const (
+	DirectionTx = "tx"
+	DirectionRx = "rx"
+)
+
+// CaptureRecord is one chunk of serial traffic. Data is hex encoded so binary
+// frames survive, Text is only there to make the file readable.
+type CaptureRecord struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Data      string    `json:"data"`
+	Text      string    `json:"text"`
+}
+
+// Bytes decodes the captured data
+func (r CaptureRecord) Bytes() ([]byte, error) {
+	return hex.DecodeString(r.Data)
+}
+
+// Capture appends every byte written to and read from the device to a JSON lines file
+type Capture struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewCapture opens (or creates) the capture file for appending
+func NewCapture(path string) (*Capture, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Capturing serial traffic to %s", path)
+	return &Capture{file: file}, nil
+}
+
+// Wrap returns an Opener whose connections record their traffic to the capture
+func (c *Capture) Wrap(open Opener) Opener {
+	return func(config Config) (Conn, string, error) {
+		conn, name, err := open(config)
+		if err != nil {
+			return nil, "", err
+		}
+		return &captureConn{Conn: conn, capture: c}, name, nil
+	}
+}
+
+// Close closes the capture file
+func (c *Capture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+func (c *Capture) record(direction string, data []byte) {
+	line, err := json.Marshal(CaptureRecord{
+		Time:      time.Now(),
+		Direction: direction,
+		Data:      hex.EncodeToString(data),
+		Text:      string(data),
+	})
+	if err != nil {
+		log.Printf("Failed to encode capture record: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		log.Printf("Failed to write capture record: %v", err)
+	}
+}
+
+// captureConn records the traffic of the wrapped connection
+type captureConn struct {
+	Conn
+	capture *Capture
+}
+
+func (c *captureConn) Write(data []byte) (int, error) {
+	n, err := c.Conn.Write(data)
+	if n > 0 {
+		c.capture.record(DirectionTx, data[:n])
+	}
+	return n, err
+}
+
+func (c *captureConn) Read(buffer []byte) (int, error) {
+	n, err := c.Conn.Read(buffer)
+	if n > 0 {
+		c.capture.record(DirectionRx, buffer[:n])
+	}
+	return n, err
+}
+
+// GetModemStatusBits keeps the health check working on captured serial ports
+func (c *captureConn) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	if checker, ok := c.Conn.(healthChecker); ok {
+		return checker.GetModemStatusBits()
+	}
+	return &serial.ModemStatusBits{}, nil
+}
+
+// LoadCapture reads a capture file written by Capture
+func LoadCapture(path string) ([]CaptureRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []CaptureRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		if _, err := record.Bytes(); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid data: %w", path, lineNumber, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
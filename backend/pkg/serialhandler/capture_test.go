@@ -0,0 +1,75 @@
+package serialhandler
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// record runs commands against the simulator and returns the captured traffic
+func record(t *testing.T, config Config, commands []string) []CaptureRecord {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "serial.jsonl")
+	capture, err := NewCapture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	simulator := NewSimulator(config, SimulatorOptions{})
+	port := NewPortWithOpener(config, capture.Wrap(simulator.Open))
+	for _, command := range commands {
+		if _, err := port.Send(command); err != nil {
+			t.Fatalf("Send(%q): %v", command, err)
+		}
+	}
+	port.Close()
+	capture.Close()
+
+	records, err := LoadCapture(path)
+	if err != nil {
+		t.Fatalf("LoadCapture: %v", err)
+	}
+	return records
+}
+
+func TestCaptureRecordsTraffic(t *testing.T) {
+	records := record(t, genericTestConfig(), []string{"sw i02"})
+	if len(records) != 2 {
+		t.Fatalf("captured %d records, want the write and its reply: %+v", len(records), records)
+	}
+	if records[0].Direction != DirectionTx || records[0].Text != "sw i02\r\n" {
+		t.Errorf("first record = %+v, want the write", records[0])
+	}
+	if data, _ := records[1].Bytes(); records[1].Direction != DirectionRx || string(data) != "sw i02 Command OK\r\n" {
+		t.Errorf("second record = %+v, want the reply", records[1])
+	}
+}
+
+func TestReplayReproducesCapture(t *testing.T) {
+	config := genericTestConfig()
+	commands := []string{"sw i02", "read", "standby on"}
+	replayer := NewReplayer(record(t, config, commands))
+
+	port := NewPortWithOpener(config, replayer.Open)
+	defer port.Close()
+	replies := []string{"sw i02 Command OK", "Input: port 02 Output: ON", "standby on Command OK"}
+	for i, command := range commands {
+		if reply, err := port.Send(command); err != nil || reply != replies[i] {
+			t.Errorf("Send(%q) = %q, %v, want %q", command, reply, err, replies[i])
+		}
+	}
+	if !replayer.Done() || len(replayer.Mismatches()) != 0 {
+		t.Errorf("replay done %t, mismatches %q", replayer.Done(), replayer.Mismatches())
+	}
+}
+
+func TestReplayReportsMismatch(t *testing.T) {
+	config := genericTestConfig()
+	replayer := NewReplayer(record(t, config, []string{"sw i02"}))
+
+	port := NewPortWithOpener(config, replayer.Open)
+	defer port.Close()
+	port.Send("sw i01")
+	if mismatches := replayer.Mismatches(); len(mismatches) != 1 {
+		t.Errorf("mismatches = %q, want one", mismatches)
+	}
+}
@@ -2,22 +2,83 @@ package serialhandler
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 )
 
 // Config holds all configuration options for your application.
 type Config struct {
-	BaudRate         int               `json:"baud_rate"`
-	DataBits         int               `json:"data_bits"`
-	StopBits         int               `json:"stop_bits"`
-	Parity           string            `json:"parity"`
-	LabeledCommands  map[string]string `json:"labeled_commands"`
-	StartupCommands  []string          `json:"startup_commands"`
-	TVBroadcastIP    string            `json:"tv_broadcast_ip"`
-	TVMacAddress     string            `json:"tv_macaddress"`
-	ServerPort       int               `json:"server_port"`
-	MeetingRoomEmail string            `json:"meeting_room_email"`
+	Driver           string                 `json:"driver"`
+	Transport        TransportConfig        `json:"transport"`
+	Device           string                 `json:"device"`
+	USBVendorID      string                 `json:"usb_vid"`
+	USBProductID     string                 `json:"usb_pid"`
+	SerialNumber     string                 `json:"serial_number"`
+	BaudRate         int                    `json:"baud_rate"`
+	DataBits         int                    `json:"data_bits"`
+	StopBits         int                    `json:"stop_bits"`
+	Parity           string                 `json:"parity"`
+	LabeledCommands  map[string]string      `json:"labeled_commands"`
+	Inputs           []InputConfig          `json:"inputs"`
+	StartupCommands  []string               `json:"startup_commands"`
+	Ack              AckConfig              `json:"ack"`
+	CommandAcks      map[string]AckConfig   `json:"command_acks"`
+	StateQuery       StateQueryConfig       `json:"state_query"`
+	Kramer           KramerConfig           `json:"kramer"`
+	Scenes           map[string]SceneConfig `json:"scenes"`
+	Devices          []DeviceConfig         `json:"devices"`
+	Display          DisplayConfig          `json:"display"`
+	WakeOnLan        []WakeOnLanTarget      `json:"wake_on_lan"`
+	Shutdown         []ShutdownStep         `json:"shutdown"`
+	TVBroadcastIP    string                 `json:"tv_broadcast_ip"`
+	TVMacAddress     string                 `json:"tv_macaddress"`
+	ServerPort       int                    `json:"server_port"`
+	MeetingRoomEmail string                 `json:"meeting_room_email"`
+	Calendar         CalendarConfig         `json:"calendar"`
+}
+
+// DisplayConfig selects how the TV or projector is controlled. The "wol"
+// driver (default) uses tv_macaddress and tv_broadcast_ip, the others connect
+// to Host/Port or, for the RS232 drivers, to Device.
+type DisplayConfig struct {
+	Driver    string `json:"driver"`
+	Host      string `json:"host"`
+	Port      int    `json:"port"`
+	Password  string `json:"password"`
+	TimeoutMs int    `json:"timeout_ms"`
+	// Device and BaudRate connect over RS232 instead of Host/Port
+	Device   string `json:"device"`
+	BaudRate int    `json:"baud_rate"`
+	// ID is the display's set ID for Samsung MDC and LG
+	ID int `json:"id"`
+	// Input is selected on the display when it is powered on, in the driver's own input codes
+	Input string `json:"input"`
+	// Class is the PJLink class, 0 asks the projector
+	Class int `json:"class"`
+	// Switcher and the commands are used by the cec driver, which powers the
+	// display through HDMI-CEC commands of a switcher (the first one by default)
+	Switcher        string `json:"switcher"`
+	PowerOnCommand  string `json:"power_on_command"`
+	PowerOffCommand string `json:"power_off_command"`
+	// Targets limits the wol driver to these wake_on_lan targets, all by default
+	Targets []string `json:"targets"`
+	// Ready is how the backend confirms the display is up after powering it on
+	Ready ReadyConfig `json:"ready"`
+}
+
+// ReadyConfig decides when a display that was powered on counts as ready.
+// Without a probe the power on command is sent once and not confirmed.
+type ReadyConfig struct {
+	// Probe is "power" (ask the display driver), "tcp" (connect to Host:Port) or "ping"
+	Probe string `json:"probe"`
+	// Host defaults to the display host
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	// Retries is how often the power on command (magic packet) is sent again
+	Retries         int `json:"retries"`
+	RetryIntervalMs int `json:"retry_interval_ms"`
+	TimeoutMs       int `json:"timeout_ms"`
 }
 
 // AppConfig is a package-level variable that will hold your application's configuration.
@@ -44,8 +105,67 @@ func LoadConfig(filePath string) (*Config, error) {
 		return nil, err
 	}
 
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.validateWakeOnLan(); err != nil {
+		return nil, err
+	}
+	if err := config.Display.validate(); err != nil {
+		return nil, err
+	}
+	if err := config.validateDevices(); err != nil {
+		return nil, err
+	}
+	if err := config.validateShutdown(); err != nil {
+		return nil, err
+	}
+	if err := config.validateScenes(); err != nil {
+		return nil, err
+	}
+
 	// Set the global configuration variable
 	AppConfig = &config
 
 	return &config, nil
 }
+
+// validate catches an unknown ready probe at startup
+func (d DisplayConfig) validate() error {
+	switch d.Ready.Probe {
+	case "", "power", "tcp", "ping":
+	default:
+		return fmt.Errorf("unknown ready probe %q", d.Ready.Probe)
+	}
+	if d.Ready.Probe == "tcp" && d.Ready.Port == 0 {
+		return fmt.Errorf("tcp ready probe needs a port")
+	}
+	if d.Driver == "cec" && d.PowerOnCommand == "" && d.PowerOffCommand == "" {
+		return fmt.Errorf("cec display needs a power_on_command or power_off_command")
+	}
+	return nil
+}
+
+// validate checks the settings of one switcher
+func (c *Config) validate() error {
+	if err := c.Transport.validate(); err != nil {
+		return err
+	}
+	if err := c.validateInputs(); err != nil {
+		return err
+	}
+	if err := c.validateCommands(); err != nil {
+		return err
+	}
+
+	// Catch invalid acknowledgment patterns at startup rather than on the first command
+	if _, err := c.Ack.compile(); err != nil {
+		return err
+	}
+	for command, ack := range c.CommandAcks {
+		if _, err := c.Ack.merge(ack).compile(); err != nil {
+			return fmt.Errorf("command_acks[%q]: %w", command, err)
+		}
+	}
+	return nil
+}
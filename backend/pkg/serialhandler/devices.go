@@ -0,0 +1,91 @@
+package serialhandler
+
+import "fmt"
+
+// Device types
+const (
+	DeviceSwitcher = "switcher"
+	DeviceDisplay  = "display"
+)
+
+// DeviceConfig is one named device of the room. Switchers (HDMI matrix,
+// audio DSP, ...) use the same keys as a single-device config.json, displays
+// use the "display" block.
+type DeviceConfig struct {
+	Name string `json:"name"`
+	// Type is "switcher" (default) or "display"
+	Type string `json:"type"`
+	Config
+}
+
+// DeviceList returns the devices of the room. Configs without a "devices"
+// list describe one switcher named "switcher" and one display named "display".
+func (c Config) DeviceList() []DeviceConfig {
+	if len(c.Devices) > 0 {
+		devices := make([]DeviceConfig, len(c.Devices))
+		for i, device := range c.Devices {
+			if device.Type == "" {
+				device.Type = DeviceSwitcher
+			}
+			devices[i] = device
+		}
+		return devices
+	}
+
+	single := c
+	single.Devices = nil
+	return []DeviceConfig{
+		{Name: "switcher", Type: DeviceSwitcher, Config: single},
+		{Name: "display", Type: DeviceDisplay, Config: single},
+	}
+}
+
+// DeviceByName looks up a device, an empty name is the first device of the given type
+func (c Config) DeviceByName(name, deviceType string) (DeviceConfig, bool) {
+	for _, device := range c.DeviceList() {
+		if name == "" && device.Type == deviceType || name != "" && device.Name == name {
+			return device, true
+		}
+	}
+	return DeviceConfig{}, false
+}
+
+// validateDevices checks the names and types and validates every device on its own
+func (c Config) validateDevices() error {
+	names := map[string]bool{}
+	for _, device := range c.Devices {
+		if device.Name == "" {
+			return fmt.Errorf("device without a name")
+		}
+		if names[device.Name] {
+			return fmt.Errorf("duplicate device name %q", device.Name)
+		}
+		names[device.Name] = true
+		if len(device.Devices) > 0 {
+			return fmt.Errorf("device %q has its own devices, devices can not be nested", device.Name)
+		}
+
+		switch device.Type {
+		case "", DeviceSwitcher:
+		case DeviceDisplay:
+			if err := device.validateWakeOnLan(); err != nil {
+				return fmt.Errorf("device %q: %w", device.Name, err)
+			}
+			if err := device.Display.validate(); err != nil {
+				return fmt.Errorf("device %q: %w", device.Name, err)
+			}
+			if device.Display.Switcher != "" {
+				if switcher, ok := c.DeviceByName(device.Display.Switcher, DeviceSwitcher); !ok || switcher.Type != DeviceSwitcher {
+					return fmt.Errorf("device %q: unknown switcher %q", device.Name, device.Display.Switcher)
+				}
+			}
+			continue
+		default:
+			return fmt.Errorf("device %q has unknown type %q", device.Name, device.Type)
+		}
+		if err := device.Config.validate(); err != nil {
+			return fmt.Errorf("device %q: %w", device.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package serialhandler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateDevices(t *testing.T) {
+	tests := []struct {
+		name    string
+		devices []DeviceConfig
+		err     string
+	}{
+		{"valid", []DeviceConfig{{Name: "matrix"}, {Name: "dsp", Type: DeviceSwitcher}}, ""},
+		{"no name", []DeviceConfig{{Type: DeviceSwitcher}}, "device without a name"},
+		{"duplicate", []DeviceConfig{{Name: "matrix"}, {Name: "matrix"}}, "duplicate device name"},
+		{"unknown type", []DeviceConfig{{Name: "matrix", Type: "toaster"}}, "unknown type"},
+		{"nested", []DeviceConfig{{Name: "matrix", Config: Config{Devices: []DeviceConfig{{Name: "inner"}}}}}, "can not be nested"},
+	}
+	for _, test := range tests {
+		err := Config{Devices: test.devices}.validateDevices()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: error = %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestDeviceList(t *testing.T) {
+	single := Config{Device: "/dev/ttyUSB0"}
+	devices := single.DeviceList()
+	if len(devices) != 2 || devices[0].Name != "switcher" || devices[1].Type != DeviceDisplay {
+		t.Fatalf("DeviceList of a single-device config = %+v", devices)
+	}
+
+	config := Config{Devices: []DeviceConfig{{Name: "matrix"}, {Name: "tv", Type: DeviceDisplay}, {Name: "dsp"}}}
+	if device, ok := config.DeviceByName("", DeviceSwitcher); !ok || device.Name != "matrix" {
+		t.Errorf("first switcher = %q, want matrix", device.Name)
+	}
+	if device, ok := config.DeviceByName("dsp", DeviceSwitcher); !ok || device.Type != DeviceSwitcher {
+		t.Errorf("DeviceByName(dsp) = %+v, %v", device, ok)
+	}
+	if _, ok := config.DeviceByName("projector", DeviceDisplay); ok {
+		t.Error("DeviceByName found a device that is not configured")
+	}
+}
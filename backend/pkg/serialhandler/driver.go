@@ -0,0 +1,88 @@
+package serialhandler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Power states reported in State.Power
+const (
+	PowerStateOn      = "on"
+	PowerStateStandby = "standby"
+	PowerStateUnknown = "unknown"
+)
+
+// State is the last known state of the switcher
+type State struct {
+	Input       int       `json:"input"`
+	Power       string    `json:"power"`
+	LastUpdated time.Time `json:"lastUpdated"`
+	// Details holds protocol specific information like the firmware version
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// SwitcherDriver is implemented by every HDMI matrix protocol the backend can talk to.
+// Inputs are numbered from 1 like on the front panel of the switcher.
+type SwitcherDriver interface {
+	SelectInput(input int) error
+	PowerOn() error
+	PowerOff() error
+	QueryState() (State, error)
+	Close()
+}
+
+// ErrUnsupported is returned for actions the switcher has no command for,
+// e.g. turn_off on an audio DSP
+var ErrUnsupported = errors.New("not supported by this switcher")
+
+// drivers maps the "driver" key in config.json to the constructor for that protocol
+var drivers = map[string]func(port *Port) (SwitcherDriver, error){
+	"generic": newGenericDriver,
+	"extron":  newExtronDriver,
+	"kramer":  newKramerDriver,
+}
+
+// driverAcks are the acknowledgment rules of each protocol, the port picks
+// them up from config.Driver so they already apply to the startup commands
+var driverAcks = map[string]AckConfig{
+	"extron": extronAck,
+	"kramer": kramerAck,
+}
+
+// NewDriver wraps the port in the driver selected by config.Driver (defaults to "generic")
+func NewDriver(config Config, port *Port) (SwitcherDriver, error) {
+	name := config.Driver
+	if name == "" {
+		name = "generic"
+	}
+
+	newDriver, ok := drivers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown switcher driver %q", name)
+	}
+
+	log.Printf("Using switcher driver: %s", name)
+	return newDriver(port)
+}
+
+// pollState runs query every interval while the port is connected, until done is closed
+func pollState(port *Port, interval time.Duration, done <-chan struct{}, query func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !port.Connected() {
+				continue
+			}
+			if err := query(); err != nil {
+				log.Printf("Failed to poll switcher state: %v", err)
+			}
+		}
+	}
+}
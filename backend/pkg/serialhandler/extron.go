@@ -0,0 +1,183 @@
+package serialhandler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// extronErrors describes the SIS error codes
+var extronErrors = map[string]string{
+	"E01": "invalid input number",
+	"E06": "invalid input change",
+	"E10": "invalid command",
+	"E11": "invalid preset number",
+	"E12": "invalid output number",
+	"E13": "invalid parameter",
+	"E14": "not valid for this configuration",
+	"E17": "system timed out",
+	"E22": "busy",
+	"E24": "privilege violation",
+	"E25": "device not present",
+	"E26": "maximum connections exceeded",
+	"E28": "bad file name",
+}
+
+// SIS commands are not terminated, replies end in CR LF and errors are "Exx"
+var extronAck = AckConfig{
+	ErrorPattern:    `^E\d\d$`,
+	Terminator:      "\r\n",
+	WriteTerminator: noTerminator,
+}
+
+var (
+	// "In2 All" is both the reply to a tie and what the switcher announces on
+	// front panel changes, the input query may only return the number
+	extronTie     = regexp.MustCompile(`^(?:In)?0*(\d+)(?: (?:All|Vid|RGB))?$`)
+	extronMute    = regexp.MustCompile(`^(?:Vmt)?([0-2])$`)
+	extronVersion = regexp.MustCompile(`^\d+(?:\.\d+)+`)
+)
+
+// extronDriver speaks the Extron Simple Instruction Set. Powering off mutes
+// the video outputs since the switchers have no standby.
+type extronDriver struct {
+	port *Port
+
+	mu    sync.Mutex
+	state State
+	done  chan struct{}
+}
+
+func newExtronDriver(port *Port) (SwitcherDriver, error) {
+	d := &extronDriver{
+		port:  port,
+		state: State{Power: PowerStateUnknown, Details: map[string]string{}},
+		done:  make(chan struct{}),
+	}
+
+	port.OnUnsolicited(func(line string) { d.parseLine(line) })
+	if interval := port.Config.StateQuery.PollIntervalMs; interval > 0 {
+		go pollState(port, time.Duration(interval)*time.Millisecond, d.done, d.query)
+	}
+	return d, nil
+}
+
+// SelectInput ties the input to all outputs, "N!" -> "InN All"
+func (d *extronDriver) SelectInput(input int) error {
+	reply, err := d.send(fmt.Sprintf("%d!", input), fmt.Sprintf(`^In0*%d `, input))
+	if err != nil {
+		return err
+	}
+	d.parseLine(reply)
+	return nil
+}
+
+// PowerOn unmutes the video, "0B" -> "Vmt0"
+func (d *extronDriver) PowerOn() error {
+	reply, err := d.send("0B", `^Vmt0$`)
+	if err != nil {
+		return err
+	}
+	d.parseLine(reply)
+	return nil
+}
+
+// PowerOff mutes the video, "1B" -> "Vmt1"
+func (d *extronDriver) PowerOff() error {
+	reply, err := d.send("1B", `^Vmt1$`)
+	if err != nil {
+		return err
+	}
+	d.parseLine(reply)
+	return nil
+}
+
+func (d *extronDriver) QueryState() (State, error) {
+	err := d.query()
+	return d.current(), err
+}
+
+func (d *extronDriver) Close() {
+	close(d.done)
+	d.port.Close()
+}
+
+// query reads the tied input ("!"), the video mute ("B") and the firmware version ("Q")
+func (d *extronDriver) query() error {
+	input, err := d.send("!", extronTie.String())
+	if err != nil {
+		return err
+	}
+	mute, err := d.send("B", extronMute.String())
+	if err != nil {
+		return err
+	}
+	firmware, err := d.send("Q", extronVersion.String())
+	if err != nil {
+		return err
+	}
+
+	d.parseLine(input)
+	if match := extronMute.FindStringSubmatch(mute); match != nil {
+		d.parseLine("Vmt" + match[1])
+	}
+
+	d.mu.Lock()
+	d.state.Details["firmware"] = firmware
+	d.mu.Unlock()
+	return nil
+}
+
+// send runs a command that is acknowledged by a reply matching success and
+// turns "Exx" replies into errors with a readable description
+func (d *extronDriver) send(command, success string) (string, error) {
+	reply, err := d.port.SendWithAck(command, AckConfig{SuccessPattern: success})
+
+	var deviceErr *DeviceError
+	if errors.As(err, &deviceErr) {
+		if description, ok := extronErrors[deviceErr.Reply]; ok {
+			return reply, fmt.Errorf("%w (%s)", err, description)
+		}
+	}
+	return reply, err
+}
+
+// parseLine updates the state from a tie or mute reply, or the same
+// message sent unsolicited when the front panel is used
+func (d *extronDriver) parseLine(line string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if match := extronMute.FindStringSubmatch(line); len(match) > 1 && line != match[1] {
+		// Vmt1 mutes the video, Vmt2 also blanks the sync
+		if match[1] == "0" {
+			d.state.Power = PowerStateOn
+		} else {
+			d.state.Power = PowerStateStandby
+		}
+		d.state.LastUpdated = time.Now()
+		return
+	}
+
+	if match := extronTie.FindStringSubmatch(line); len(match) > 1 {
+		if input, err := strconv.Atoi(match[1]); err == nil {
+			d.state.Input = input
+			d.state.LastUpdated = time.Now()
+		}
+	}
+}
+
+func (d *extronDriver) current() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state := d.state
+	state.Details = map[string]string{}
+	for key, value := range d.state.Details {
+		state.Details[key] = value
+	}
+	return state
+}
@@ -0,0 +1,181 @@
+package serialhandler
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newSimulatedDriver connects the driver selected by config to a simulator
+func newSimulatedDriver(t *testing.T, config Config) (SwitcherDriver, *Simulator) {
+	t.Helper()
+	simulator := NewSimulator(config, SimulatorOptions{})
+	port := NewPortWithOpener(config, simulator.Open)
+	driver, err := NewDriver(config, port)
+	if err != nil {
+		port.Close()
+		t.Fatalf("NewDriver: %v", err)
+	}
+	t.Cleanup(driver.Close)
+	return driver, simulator
+}
+
+func extronTestConfig() Config {
+	return Config{
+		Driver: "extron",
+		Inputs: []InputConfig{{ID: 1, Name: "pc"}, {ID: 2, Name: "laptop"}, {ID: 3, Name: "camera"}},
+	}
+}
+
+func TestExtronParseLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		input int
+		power string
+	}{
+		{"In2 All", 2, PowerStateUnknown},
+		{"In03 Vid", 3, PowerStateUnknown},
+		{"Vmt1", 0, PowerStateStandby},
+		{"Vmt2", 0, PowerStateStandby},
+		{"Vmt0", 0, PowerStateOn},
+		{"E10", 0, PowerStateUnknown},
+	}
+	for _, test := range tests {
+		d := &extronDriver{state: State{Power: PowerStateUnknown, Details: map[string]string{}}}
+		d.parseLine(test.line)
+		if d.state.Input != test.input || d.state.Power != test.power {
+			t.Errorf("parseLine(%q) = input %d power %s, want input %d power %s", test.line, d.state.Input, d.state.Power, test.input, test.power)
+		}
+	}
+}
+
+func TestExtronSelectInputAndPower(t *testing.T) {
+	driver, simulator := newSimulatedDriver(t, extronTestConfig())
+
+	if err := driver.SelectInput(2); err != nil {
+		t.Fatalf("SelectInput: %v", err)
+	}
+	if err := driver.PowerOff(); err != nil {
+		t.Fatalf("PowerOff: %v", err)
+	}
+	if state := simulator.State(); state.Input != 2 || state.Power != PowerStateStandby {
+		t.Errorf("simulator state = %+v, want input 2 in standby", state)
+	}
+
+	state, err := driver.QueryState()
+	if err != nil {
+		t.Fatalf("QueryState: %v", err)
+	}
+	if state.Input != 2 || state.Power != PowerStateStandby || state.Details["firmware"] != "1.00" {
+		t.Errorf("QueryState = %+v, want input 2, standby, firmware 1.00", state)
+	}
+}
+
+// The generic "ack" block of a shipped config must not hide the SIS error replies
+func TestExtronErrorsWithGenericAck(t *testing.T) {
+	config := extronTestConfig()
+	config.Ack = AckConfig{SuccessPattern: "(?i)ok", ErrorPattern: "(?i)command incorrect", TimeoutMs: 1000}
+	driver, _ := newSimulatedDriver(t, config)
+
+	err := driver.SelectInput(9)
+	if !errors.Is(err, ErrDeviceRejected) {
+		t.Fatalf("SelectInput(9) = %v, want ErrDeviceRejected", err)
+	}
+	if !strings.Contains(err.Error(), "invalid input number") {
+		t.Errorf("error %q does not describe E01", err)
+	}
+
+	if _, err := driver.QueryState(); err != nil {
+		t.Errorf("QueryState: %v", err)
+	}
+}
+
+func TestExtronFrontPanelChange(t *testing.T) {
+	driver, simulator := newSimulatedDriver(t, extronTestConfig())
+
+	simulator.PressRemote(3)
+	deadline := time.Now().Add(2 * time.Second)
+	for driver.(*extronDriver).current().Input != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("front panel change to input 3 was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// An "ack" terminator left from a generic config splits neither the replies nor
+// the unsolicited lines differently from the SIS protocol
+func TestExtronIgnoresAckTerminator(t *testing.T) {
+	config := extronTestConfig()
+	config.Ack = AckConfig{Terminator: "\r"}
+	driver, simulator := newSimulatedDriver(t, config)
+	port := driver.(*extronDriver).port
+
+	data := "In3 All\r\nVmt1\r\nIn"
+	matcher, err := port.ackFor("1!", AckConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replies, replyRest := matcher.split(data)
+	port.mu.Lock()
+	unsolicited, unsolicitedRest := port.splitUnsolicited(data)
+	port.mu.Unlock()
+	if strings.Join(replies, "|") != "In3 All|Vmt1" || strings.Join(unsolicited, "|") != "In3 All|Vmt1" || replyRest != "In" || unsolicitedRest != "In" {
+		t.Errorf("replies %q rest %q, unsolicited lines %q rest %q, want both split on CRLF", replies, replyRest, unsolicited, unsolicitedRest)
+	}
+
+	if err := driver.SelectInput(2); err != nil {
+		t.Fatalf("SelectInput: %v", err)
+	}
+	simulator.PressRemote(3)
+	deadline := time.Now().Add(2 * time.Second)
+	for driver.(*extronDriver).current().Input != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("front panel change to input 3 was not picked up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// recordingConn keeps a copy of everything written to the device
+type recordingConn struct {
+	Conn
+	mu      sync.Mutex
+	written strings.Builder
+}
+
+func (c *recordingConn) Write(data []byte) (int, error) {
+	c.mu.Lock()
+	c.written.Write(data)
+	c.mu.Unlock()
+	return c.Conn.Write(data)
+}
+
+func TestExtronStartupCommandsUseDriverAck(t *testing.T) {
+	config := extronTestConfig()
+	config.StartupCommands = []string{"2!"}
+	simulator := NewSimulator(config, SimulatorOptions{})
+	var conn *recordingConn
+	port := NewPortWithOpener(config, func(config Config) (Conn, string, error) {
+		simulated, name, err := simulator.Open(config)
+		if err != nil {
+			return nil, "", err
+		}
+		conn = &recordingConn{Conn: simulated}
+		return conn, name, nil
+	})
+	defer port.Close()
+
+	// The connection and the startup commands happen before NewPortWithOpener returns
+	conn.mu.Lock()
+	written := conn.written.String()
+	conn.mu.Unlock()
+	if written != "2!" {
+		t.Errorf("startup commands wrote %q, want %q without a terminator", written, "2!")
+	}
+	if input := simulator.State().Input; input != 2 {
+		t.Errorf("simulator input = %d, want 2", input)
+	}
+}
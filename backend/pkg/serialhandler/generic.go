@@ -0,0 +1,170 @@
+package serialhandler
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StateQueryConfig tells the generic driver how to read the switcher's state.
+// The patterns are matched against query replies and against unsolicited
+// lines, so changes made with the hardware remote are picked up as well.
+type StateQueryConfig struct {
+	Commands []string `json:"commands"`
+	// InputPattern must capture the input number in its first group
+	InputPattern   string `json:"input_pattern"`
+	PowerOnPattern string `json:"power_on_pattern"`
+	StandbyPattern string `json:"standby_pattern"`
+	PollIntervalMs int    `json:"poll_interval_ms"`
+}
+
+// genericDriver sends the free-form strings from LabeledCommands ("sw iNN", "standby on", ...)
+type genericDriver struct {
+	port *Port
+
+	input   *regexp.Regexp
+	powerOn *regexp.Regexp
+	standby *regexp.Regexp
+
+	mu    sync.Mutex
+	state State
+	done  chan struct{}
+}
+
+func newGenericDriver(port *Port) (SwitcherDriver, error) {
+	query := port.Config.StateQuery
+	d := &genericDriver{
+		port:  port,
+		state: State{Power: PowerStateUnknown},
+		done:  make(chan struct{}),
+	}
+
+	var err error
+	if d.input, err = compileOptional(query.InputPattern); err != nil {
+		return nil, fmt.Errorf("state_query.input_pattern: %w", err)
+	}
+	if d.powerOn, err = compileOptional(query.PowerOnPattern); err != nil {
+		return nil, fmt.Errorf("state_query.power_on_pattern: %w", err)
+	}
+	if d.standby, err = compileOptional(query.StandbyPattern); err != nil {
+		return nil, fmt.Errorf("state_query.standby_pattern: %w", err)
+	}
+
+	port.OnUnsolicited(func(line string) { d.parseLine(line) })
+	if query.PollIntervalMs > 0 && len(query.Commands) > 0 {
+		go pollState(port, time.Duration(query.PollIntervalMs)*time.Millisecond, d.done, d.query)
+	}
+	return d, nil
+}
+
+func (d *genericDriver) SelectInput(input int) error {
+	selected, ok := d.port.Config.InputByID(input)
+	if !ok || selected.Command == "" {
+		return fmt.Errorf("no command configured for input %d", input)
+	}
+	if err := d.port.Write(selected.Command); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.state.Input = input
+	d.state.LastUpdated = time.Now()
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *genericDriver) PowerOn() error {
+	return d.setPower("turn_on", PowerStateOn)
+}
+
+func (d *genericDriver) PowerOff() error {
+	return d.setPower("turn_off", PowerStateStandby)
+}
+
+// QueryState asks the switcher when state_query commands are configured,
+// otherwise it returns the state implied by the commands sent so far
+func (d *genericDriver) QueryState() (State, error) {
+	if err := d.query(); err != nil {
+		return d.current(), err
+	}
+	return d.current(), nil
+}
+
+func (d *genericDriver) Close() {
+	close(d.done)
+	d.port.Close()
+}
+
+func (d *genericDriver) setPower(label, power string) error {
+	command, err := d.command(label)
+	if err != nil {
+		return err
+	}
+	if err := d.port.Write(command); err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.state.Power = power
+	d.state.LastUpdated = time.Now()
+	d.mu.Unlock()
+	return nil
+}
+
+// command looks up a labeled command from config.json, a missing one is ErrUnsupported
+func (d *genericDriver) command(label string) (string, error) {
+	command, ok := d.port.Config.LabeledCommands[label]
+	if !ok || command == "" {
+		return "", fmt.Errorf("no command configured for %q: %w", label, ErrUnsupported)
+	}
+	return command, nil
+}
+
+// query sends the state_query commands and parses their replies
+func (d *genericDriver) query() error {
+	for _, command := range d.port.Config.StateQuery.Commands {
+		reply, err := d.port.Send(command)
+		if err != nil {
+			return err
+		}
+		d.parseLine(reply)
+	}
+	return nil
+}
+
+// parseLine updates the state from a status line
+func (d *genericDriver) parseLine(line string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.input != nil {
+		if match := d.input.FindStringSubmatch(line); len(match) > 1 {
+			if input, err := strconv.Atoi(match[1]); err == nil {
+				d.state.Input = input
+				d.state.LastUpdated = time.Now()
+			}
+		}
+	}
+	if d.standby != nil && d.standby.MatchString(line) {
+		d.state.Power = PowerStateStandby
+		d.state.LastUpdated = time.Now()
+	} else if d.powerOn != nil && d.powerOn.MatchString(line) {
+		d.state.Power = PowerStateOn
+		d.state.LastUpdated = time.Now()
+	}
+}
+
+func (d *genericDriver) current() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state
+}
+
+func compileOptional(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
@@ -0,0 +1,85 @@
+package serialhandler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InputConfig is one switcher input as shown in the UI
+type InputConfig struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Icon        string `json:"icon"`
+	Command     string `json:"command"`
+}
+
+// InputList returns the configured inputs ordered by ID. Configs without an
+// "inputs" list get one input per "input_N" entry in labeled_commands.
+func (c Config) InputList() []InputConfig {
+	inputs := append([]InputConfig(nil), c.Inputs...)
+
+	if len(inputs) == 0 {
+		for label, command := range c.LabeledCommands {
+			number, ok := strings.CutPrefix(label, "input_")
+			if !ok {
+				continue
+			}
+			id, err := strconv.Atoi(number)
+			if err != nil {
+				continue
+			}
+			inputs = append(inputs, InputConfig{
+				ID:          id,
+				Name:        label,
+				DisplayName: fmt.Sprintf("Input %d", id),
+				Command:     command,
+			})
+		}
+	}
+
+	sort.Slice(inputs, func(i, j int) bool { return inputs[i].ID < inputs[j].ID })
+	return inputs
+}
+
+// InputByID looks up an input by its number on the switcher
+func (c Config) InputByID(id int) (InputConfig, bool) {
+	for _, input := range c.InputList() {
+		if input.ID == id {
+			return input, true
+		}
+	}
+	return InputConfig{}, false
+}
+
+// InputByName looks up an input by the name used in the API
+func (c Config) InputByName(name string) (InputConfig, bool) {
+	for _, input := range c.InputList() {
+		if input.Name == name {
+			return input, true
+		}
+	}
+	return InputConfig{}, false
+}
+
+// validateInputs makes sure inputs can be told apart by both ID and name
+func (c Config) validateInputs() error {
+	ids := map[int]bool{}
+	names := map[string]bool{}
+	for _, input := range c.Inputs {
+		if input.Name == "" {
+			return fmt.Errorf("input %d has no name", input.ID)
+		}
+		if ids[input.ID] {
+			return fmt.Errorf("duplicate input id %d", input.ID)
+		}
+		if names[input.Name] {
+			return fmt.Errorf("duplicate input name %q", input.Name)
+		}
+		ids[input.ID] = true
+		names[input.Name] = true
+	}
+	return nil
+}
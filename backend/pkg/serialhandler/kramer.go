@@ -0,0 +1,273 @@
+package serialhandler
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KramerConfig holds the Protocol 3000 settings of the "kramer" driver
+type KramerConfig struct {
+	// MachineID addresses one device on a shared bus, 0 leaves it out
+	MachineID int `json:"machine_id"`
+	// Output is the output that inputs are routed to, 0 routes to all outputs
+	Output int `json:"output"`
+	// PowerMode is "vmute" (the default) to mute the video for off, or "standby"
+	PowerMode string `json:"power_mode"`
+}
+
+// kramerErrors describes the Protocol 3000 error codes
+var kramerErrors = map[string]string{
+	"001": "syntax error",
+	"002": "command not available",
+	"003": "parameter out of range",
+	"004": "unauthorized access",
+	"005": "internal firmware error",
+	"006": "protocol busy",
+	"007": "wrong CRC",
+	"008": "timed out",
+	"010": "reserved",
+	"011": "not enough space for data",
+	"012": "not enough space for file",
+	"014": "file does not exist",
+	"015": "file cannot be created",
+	"016": "file cannot open",
+	"017": "feature not supported",
+}
+
+// Requests end in CR, replies in CR LF, errors look like "~01@ROUTE ERR 003"
+var kramerAck = AckConfig{
+	ErrorPattern:    `(?i)^~\d*@.*\bERR\s*\d+`,
+	Terminator:      "\r\n",
+	WriteTerminator: "\r",
+}
+
+// kramerReply splits "~01@ROUTE 1,1,2 OK" into the command, its parameters and the OK
+var kramerReply = regexp.MustCompile(`(?i)^~(\d*)@\s*([A-Z0-9\-_?]+)\s*([^ ]*)\s*(OK|ERR\s*(\d+))?\s*$`)
+
+// kramerDriver speaks Kramer Protocol 3000. Routing uses the video layer (1).
+type kramerDriver struct {
+	port   *Port
+	config KramerConfig
+
+	mu    sync.Mutex
+	state State
+	done  chan struct{}
+}
+
+func newKramerDriver(port *Port) (SwitcherDriver, error) {
+	config := port.Config.Kramer
+	switch config.PowerMode {
+	case "":
+		config.PowerMode = "vmute"
+	case "vmute", "standby":
+	default:
+		return nil, fmt.Errorf("kramer.power_mode must be vmute or standby, got %q", config.PowerMode)
+	}
+
+	d := &kramerDriver{
+		port:   port,
+		config: config,
+		state:  State{Power: PowerStateUnknown, Details: map[string]string{}},
+		done:   make(chan struct{}),
+	}
+
+	port.OnUnsolicited(func(line string) { d.parseLine(line) })
+	if interval := port.Config.StateQuery.PollIntervalMs; interval > 0 {
+		go pollState(port, time.Duration(interval)*time.Millisecond, d.done, d.query)
+	}
+	return d, nil
+}
+
+// SelectInput routes the input to the output, "#ROUTE 1,1,2" -> "~01@ROUTE 1,1,2 OK"
+func (d *kramerDriver) SelectInput(input int) error {
+	_, err := d.send("ROUTE", fmt.Sprintf("1,%s,%d", d.output(), input))
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.state.Input = input
+	d.state.LastUpdated = time.Now()
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *kramerDriver) PowerOn() error {
+	return d.setPower(false)
+}
+
+func (d *kramerDriver) PowerOff() error {
+	return d.setPower(true)
+}
+
+func (d *kramerDriver) QueryState() (State, error) {
+	err := d.query()
+	return d.current(), err
+}
+
+func (d *kramerDriver) Close() {
+	close(d.done)
+	d.port.Close()
+}
+
+// setPower mutes the video ("#VMUTE 1,1") or puts the device in standby ("#STANDBY 1")
+func (d *kramerDriver) setPower(off bool) error {
+	flag := "0"
+	if off {
+		flag = "1"
+	}
+
+	var err error
+	if d.config.PowerMode == "standby" {
+		_, err = d.send("STANDBY", flag)
+	} else {
+		_, err = d.send("VMUTE", fmt.Sprintf("%s,%s", d.output(), flag))
+	}
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.state.Power = PowerStateOn
+	if off {
+		d.state.Power = PowerStateStandby
+	}
+	d.state.LastUpdated = time.Now()
+	d.mu.Unlock()
+	return nil
+}
+
+// query reads the route, the power state and the signal on every configured input
+func (d *kramerDriver) query() error {
+	output := d.config.Output
+	if output == 0 {
+		output = 1
+	}
+
+	reply, err := d.send("ROUTE?", fmt.Sprintf("1,%d", output))
+	if err != nil {
+		return err
+	}
+	d.parseLine(reply)
+
+	if d.config.PowerMode == "standby" {
+		reply, err = d.send("STANDBY?", "")
+	} else {
+		reply, err = d.send("VMUTE?", strconv.Itoa(output))
+	}
+	if err != nil {
+		return err
+	}
+	d.parseLine(reply)
+
+	for _, input := range d.port.Config.InputList() {
+		reply, err := d.send("SIGNAL?", strconv.Itoa(input.ID))
+		if err != nil {
+			return err
+		}
+		d.parseLine(reply)
+	}
+	return nil
+}
+
+// send builds "#[id@]COMMAND params" and waits for the reply to that command.
+// ERR replies are returned with the description of their code.
+func (d *kramerDriver) send(command, params string) (string, error) {
+	request := "#" + command
+	if d.config.MachineID > 0 {
+		request = fmt.Sprintf("#%d@%s", d.config.MachineID, command)
+	}
+	if params != "" {
+		request += " " + params
+	}
+
+	// Queries are answered without the question mark
+	name := regexp.QuoteMeta(strings.TrimSuffix(command, "?"))
+	reply, err := d.port.SendWithAck(request, AckConfig{SuccessPattern: `(?i)^~\d*@\s*` + name + `\b`})
+
+	var deviceErr *DeviceError
+	if errors.As(err, &deviceErr) {
+		if match := kramerReply.FindStringSubmatch(deviceErr.Reply); len(match) > 5 {
+			if description, ok := kramerErrors[match[5]]; ok {
+				return reply, fmt.Errorf("%w (%s)", err, description)
+			}
+		}
+	}
+	return reply, err
+}
+
+// parseLine updates the state from replies and from the notifications the
+// device sends when it is switched from the front panel
+func (d *kramerDriver) parseLine(line string) {
+	match := kramerReply.FindStringSubmatch(line)
+	if len(match) == 0 || strings.HasPrefix(strings.ToUpper(match[4]), "ERR") {
+		return
+	}
+	command := strings.ToUpper(match[2])
+	params := strings.Split(match[3], ",")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch {
+	case command == "ROUTE" && len(params) == 3 && params[0] == "1" && d.isOutput(params[1]):
+		if input, err := strconv.Atoi(params[2]); err == nil {
+			d.state.Input = input
+			d.state.LastUpdated = time.Now()
+		}
+	case command == "VMUTE" && len(params) == 2 && d.config.PowerMode == "vmute" && d.isOutput(params[0]):
+		d.setPowerFlag(params[1])
+	case command == "STANDBY" && len(params) == 1 && d.config.PowerMode == "standby":
+		d.setPowerFlag(params[0])
+	case command == "SIGNAL" && len(params) == 2:
+		signal := "no"
+		if params[1] == "1" {
+			signal = "yes"
+		}
+		d.state.Details["signal_input_"+params[0]] = signal
+		d.state.LastUpdated = time.Now()
+	}
+}
+
+// setPowerFlag must be called with d.mu held
+func (d *kramerDriver) setPowerFlag(flag string) {
+	switch flag {
+	case "0":
+		d.state.Power = PowerStateOn
+	case "1", "2":
+		d.state.Power = PowerStateStandby
+	default:
+		return
+	}
+	d.state.LastUpdated = time.Now()
+}
+
+// output is the output parameter of ROUTE and VMUTE, "*" for all outputs
+func (d *kramerDriver) output() string {
+	if d.config.Output == 0 {
+		return "*"
+	}
+	return strconv.Itoa(d.config.Output)
+}
+
+// isOutput reports whether a route or mute notification concerns our output
+func (d *kramerDriver) isOutput(output string) bool {
+	return output == "*" || output == strconv.Itoa(d.config.Output) || (d.config.Output == 0 && output == "1")
+}
+
+func (d *kramerDriver) current() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state := d.state
+	state.Details = map[string]string{}
+	for key, value := range d.state.Details {
+		state.Details[key] = value
+	}
+	return state
+}
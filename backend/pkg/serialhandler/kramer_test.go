@@ -0,0 +1,78 @@
+package serialhandler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func kramerTestConfig() Config {
+	return Config{
+		Driver: "kramer",
+		Inputs: []InputConfig{{ID: 1, Name: "pc"}, {ID: 2, Name: "laptop"}},
+	}
+}
+
+func TestKramerParseLine(t *testing.T) {
+	tests := []struct {
+		line   string
+		input  int
+		power  string
+		signal string
+	}{
+		{"~01@ROUTE 1,1,2 OK", 2, PowerStateUnknown, ""},
+		{"~01@ROUTE 1,*,3", 3, PowerStateUnknown, ""},
+		{"~01@ROUTE 1,2,3", 0, PowerStateUnknown, ""},
+		{"~01@VMUTE 1,1 OK", 0, PowerStateStandby, ""},
+		{"~01@VMUTE *,0", 0, PowerStateOn, ""},
+		{"~01@SIGNAL 2,1", 0, PowerStateUnknown, "yes"},
+		{"~01@ROUTE ERR 003", 0, PowerStateUnknown, ""},
+	}
+	for _, test := range tests {
+		d := &kramerDriver{config: KramerConfig{PowerMode: "vmute"}, state: State{Power: PowerStateUnknown, Details: map[string]string{}}}
+		d.parseLine(test.line)
+		if d.state.Input != test.input || d.state.Power != test.power || d.state.Details["signal_input_2"] != test.signal {
+			t.Errorf("parseLine(%q) = %+v, want input %d power %s signal %q", test.line, d.state, test.input, test.power, test.signal)
+		}
+	}
+}
+
+func TestKramerRouteAndQuery(t *testing.T) {
+	driver, simulator := newSimulatedDriver(t, kramerTestConfig())
+
+	if err := driver.SelectInput(2); err != nil {
+		t.Fatalf("SelectInput: %v", err)
+	}
+	if err := driver.PowerOff(); err != nil {
+		t.Fatalf("PowerOff: %v", err)
+	}
+	if state := simulator.State(); state.Input != 2 || state.Power != PowerStateStandby {
+		t.Errorf("simulator state = %+v, want input 2 in standby", state)
+	}
+
+	state, err := driver.QueryState()
+	if err != nil {
+		t.Fatalf("QueryState: %v", err)
+	}
+	if state.Input != 2 || state.Power != PowerStateStandby || state.Details["signal_input_2"] != "yes" || state.Details["signal_input_1"] != "no" {
+		t.Errorf("QueryState = %+v", state)
+	}
+}
+
+// The generic "ack" block of a shipped config must not hide the Protocol 3000 ERR replies
+func TestKramerErrorsWithGenericAck(t *testing.T) {
+	config := kramerTestConfig()
+	config.Ack = AckConfig{SuccessPattern: "(?i)command ok", ErrorPattern: "(?i)command incorrect", TimeoutMs: 1000}
+	driver, _ := newSimulatedDriver(t, config)
+
+	if err := driver.SelectInput(1); err != nil {
+		t.Fatalf("SelectInput(1): %v", err)
+	}
+	err := driver.SelectInput(7)
+	if !errors.Is(err, ErrDeviceRejected) {
+		t.Fatalf("SelectInput(7) = %v, want ErrDeviceRejected", err)
+	}
+	if !strings.Contains(err.Error(), "parameter out of range") {
+		t.Errorf("error %q does not describe ERR 003", err)
+	}
+}
@@ -2,40 +2,89 @@ package serialhandler
 
 import (
 	"backend/pkg/utils"
-	"errors"
 	"log"
-	"strings"
+	"sync"
 	"time"
 
 	"go.bug.st/serial"
 )
 
 type Port struct {
-	serialPort serial.Port
-	Config     Config
+	Config Config
+
+	open Opener
+	mu   sync.Mutex
+	// conn is nil while the switcher is offline
+	conn   Conn
+	name   string
+	status ConnectionStatus
+
+	queue chan *request
+	// rx holds received data that has not been split into lines yet
+	rx string
+	// pending is signalled on new data while a command is waiting for its reply
+	pending chan struct{}
+	// driverAck are the acknowledgment rules of the protocol driver
+	driverAck AckConfig
+	// lineHandler receives lines that are not the reply to a command
+	lineHandler func(line string)
+
+	// reconnect backoff, doubling from the minimum up to the maximum
+	minReconnectDelay time.Duration
+	maxReconnectDelay time.Duration
+
+	lost      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-var selectedPort string
+// Opener opens the connection to the device and returns it together with a name for the logs
+type Opener func(config Config) (Conn, string, error)
 
-// NewPort opens the first available serial port containing "COM" instead of using config.Device (semi hardcoded name)
-func NewPort(config Config) (*Port, error) {
-	// List available serial ports
-	ports, err := serial.GetPortsList()
-	if err != nil {
-		return nil, err
-	}
+// NewPort returns a supervised port for the switcher described by config,
+// connected over the transport it configures.
+// The first connection attempt happens before returning, after that the
+// supervisor keeps reconnecting in the background, so the port is never nil.
+func NewPort(config Config) *Port {
+	return NewPortWithOpener(config, OpenTransport)
+}
+
+// NewPortWithOpener is NewPort with a custom way to open the connection
+func NewPortWithOpener(config Config, open Opener) *Port {
+	p := newPort(config, open)
+	p.start()
+	return p
+}
 
-	// Find a port containing "COM" (case-insensitive)
+// newPort returns the port without connecting it, start does that
+func newPort(config Config, open Opener) *Port {
+	return &Port{
+		Config:            config,
+		open:              open,
+		status:            ConnectionStatus{State: StateConnecting, Since: time.Now()},
+		queue:             make(chan *request),
+		driverAck:         driverAcks[config.Driver],
+		minReconnectDelay: minReconnectDelay,
+		maxReconnectDelay: maxReconnectDelay,
+		lost:              make(chan struct{}, 1),
+		done:              make(chan struct{}),
+	}
+}
 
-	for _, port := range ports {
-		if strings.Contains(strings.ToLower(port), "com") {
-			selectedPort = port
-			break
-		}
+// start makes the first connection attempt and hands the port to the supervisor
+func (p *Port) start() {
+	go p.processQueue()
+	if err := p.connect(); err != nil {
+		log.Printf("Failed to initialize serial port: %v", err)
 	}
+	go p.supervise()
+}
 
-	if selectedPort == "" {
-		return nil, errors.New("no suitable COM port found")
+// OpenSerial is the default Opener, it opens the serial port matching config.Device, the USB VID/PID or the adapter serial number
+func OpenSerial(config Config) (Conn, string, error) {
+	selectedPort, err := selectPort(config)
+	if err != nil {
+		return nil, "", err
 	}
 
 	log.Printf("Connecting to port: %s", selectedPort)
@@ -51,33 +100,39 @@ func NewPort(config Config) (*Port, error) {
 	// Open the selected serial port
 	sp, err := serial.Open(selectedPort, mode)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	// Without a read timeout Read blocks forever and the acknowledgment timeout never fires
+	if err := sp.SetReadTimeout(100 * time.Millisecond); err != nil {
+		sp.Close()
+		return nil, "", err
 	}
 
 	log.Println("Serial port successfully opened")
-	return &Port{serialPort: sp, Config: config}, nil
+	return sp, selectedPort, nil
 }
 
-// Writes the command to the serial port
+// Writes the command to the serial port and waits for the switcher to acknowledge it
 func (p *Port) Write(command string) error {
-	_, err := p.serialPort.Write([]byte(command + "\r\n"))
-	if err != nil {
-		log.Printf("Failed to write to serial port: %v", err)
-		return err
-	}
-	log.Printf("Command sent to %s: %s", selectedPort, command)
-
-	//Wait for acknowledgment
-	if p.WaitForAcknowledgment(5) != true { // Wait for up to 5 seconds
-		log.Printf("No acknowledgment received for command: %s", command)
-	}
-
-	return nil
+	_, err := p.Send(command)
+	return err
 }
 
+// Close stops the supervisor and closes the connection
 func (p *Port) Close() {
-	p.serialPort.Close()
-	log.Println("Serial port closed")
+	p.closeOnce.Do(func() {
+		close(p.done)
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.conn != nil {
+			p.conn.Close()
+			p.conn = nil
+		}
+		p.setStatus(StateClosed, nil)
+		log.Println("Switcher connection closed")
+	})
 }
 
 func (p *Port) RunStartupCommands(commands []string) {
@@ -91,62 +146,23 @@ func (p *Port) RunStartupCommands(commands []string) {
 	}
 }
 
-func (p *Port) WaitForAcknowledgment(timeoutSeconds int) bool {
-	startTime := time.Now()
-	var messageBuffer string
+// readLoop reads from conn until it fails or is replaced and buffers everything it receives
+func (p *Port) readLoop(conn Conn) {
+	buffer := make([]byte, 128)
 
 	for {
-		// Check if timeout has been reached
-		if time.Since(startTime).Seconds() > float64(timeoutSeconds) {
-			log.Printf("Acknowledgment timeout reached")
-			return false
-		}
-
-		// Read data from the serial port
-		buffer := make([]byte, 128)
-		n, err := p.serialPort.Read(buffer)
+		// Read data from the serial port, this returns 0 bytes when the read timeout expires
+		n, err := conn.Read(buffer)
 		if err != nil {
-			log.Printf("Error reading from serial port: %v", err)
-			return false
+			log.Printf("Error reading from switcher: %v", err)
+			p.connectionLost(conn, err)
+			return
 		}
-
-		if n > 0 {
-			// Append received data to the buffer
-			messageBuffer += string(buffer[:n])
-
-			// Process complete messages
-			for {
-				// Find the index of the message delimiter (e.g., '\n')
-				delimiterIndex := indexOfDelimiter(messageBuffer, "\n")
-
-				// If no delimiter is found, wait for more data
-				if delimiterIndex == -1 {
-					break
-				}
-
-				// Extract the complete message and remove it from the buffer
-				completeMessage := messageBuffer[:delimiterIndex]
-				messageBuffer = messageBuffer[delimiterIndex+1:] // +1 to remove the delimiter
-
-				// Log the complete message
-				log.Printf("Received: %s", completeMessage)
-
-				// Acknowledge the complete message
-				return true
-			}
+		if p.current() != conn {
+			return
 		}
-
-		// Sleep briefly to avoid tight looping
-		time.Sleep(100 * time.Millisecond)
-	}
-}
-
-// Helper function to find the index of a delimiter in a string
-func indexOfDelimiter(data, delimiter string) int {
-	for i := 0; i < len(data); i++ {
-		if string(data[i]) == delimiter {
-			return i
+		if n > 0 {
+			p.received(string(buffer[:n]))
 		}
 	}
-	return -1
 }
@@ -0,0 +1,109 @@
+package serialhandler
+
+import (
+	"fmt"
+	"strings"
+
+	"go.bug.st/serial/enumerator"
+)
+
+// selectPort resolves the config to exactly one serial port name.
+// An explicit device path on its own is used as-is, otherwise every configured
+// criterion (device, usb_vid, usb_pid, serial_number) has to match the same port.
+func selectPort(config Config) (string, error) {
+	hasUSBCriteria := config.USBVendorID != "" || config.USBProductID != "" || config.SerialNumber != ""
+	if config.Device != "" && !hasUSBCriteria {
+		return config.Device, nil
+	}
+
+	ports, err := enumerator.GetDetailedPortsList()
+	if err != nil {
+		return "", fmt.Errorf("failed to list serial ports: %w", err)
+	}
+	return pickPort(config, ports)
+}
+
+// pickPort returns the one port of the list matching config
+func pickPort(config Config, ports []*enumerator.PortDetails) (string, error) {
+	var matches []*enumerator.PortDetails
+	for _, port := range ports {
+		if portMatches(config, port) {
+			matches = append(matches, port)
+		}
+	}
+
+	switch {
+	case len(matches) == 1:
+		return matches[0].Name, nil
+	case len(matches) > 1:
+		return "", fmt.Errorf("%d serial ports match %s, set more of device, usb_vid, usb_pid or serial_number; candidates: %s",
+			len(matches), describeCriteria(config), describePorts(matches))
+	default:
+		return "", fmt.Errorf("no serial port matches %s; candidates: %s", describeCriteria(config), describePorts(ports))
+	}
+}
+
+// portMatches reports whether the port satisfies every criterion set in the config.
+// With no criteria at all every port matches, so a lone adapter is picked automatically.
+func portMatches(config Config, port *enumerator.PortDetails) bool {
+	if config.Device != "" && !strings.EqualFold(config.Device, port.Name) {
+		return false
+	}
+	if config.USBVendorID != "" && (!port.IsUSB || !sameHexID(config.USBVendorID, port.VID)) {
+		return false
+	}
+	if config.USBProductID != "" && (!port.IsUSB || !sameHexID(config.USBProductID, port.PID)) {
+		return false
+	}
+	if config.SerialNumber != "" && (!port.IsUSB || !strings.EqualFold(config.SerialNumber, port.SerialNumber)) {
+		return false
+	}
+	return true
+}
+
+// sameHexID compares USB IDs ignoring case and an optional "0x" prefix, since
+// the enumerator reports them in upper case on Windows and lower case on Linux
+func sameHexID(a, b string) bool {
+	trim := func(id string) string {
+		id = strings.ToLower(strings.TrimSpace(id))
+		return strings.TrimPrefix(id, "0x")
+	}
+	return trim(a) == trim(b)
+}
+
+func describeCriteria(config Config) string {
+	var criteria []string
+	if config.Device != "" {
+		criteria = append(criteria, "device="+config.Device)
+	}
+	if config.USBVendorID != "" {
+		criteria = append(criteria, "usb_vid="+config.USBVendorID)
+	}
+	if config.USBProductID != "" {
+		criteria = append(criteria, "usb_pid="+config.USBProductID)
+	}
+	if config.SerialNumber != "" {
+		criteria = append(criteria, "serial_number="+config.SerialNumber)
+	}
+	if len(criteria) == 0 {
+		return "(no criteria)"
+	}
+	return strings.Join(criteria, ", ")
+}
+
+func describePorts(ports []*enumerator.PortDetails) string {
+	if len(ports) == 0 {
+		return "none found"
+	}
+
+	var descriptions []string
+	for _, port := range ports {
+		if port.IsUSB {
+			descriptions = append(descriptions, fmt.Sprintf("%s (USB %s:%s, serial %q, %s)",
+				port.Name, port.VID, port.PID, port.SerialNumber, port.Product))
+		} else {
+			descriptions = append(descriptions, port.Name)
+		}
+	}
+	return strings.Join(descriptions, "; ")
+}
@@ -0,0 +1,64 @@
+package serialhandler
+
+import (
+	"strings"
+	"testing"
+
+	"go.bug.st/serial/enumerator"
+)
+
+func TestSameHexID(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"0403", "0403", true},
+		{"0x0403", "0403", true},
+		{"0X067B", "067b", true},
+		{" 067b ", "067B", true},
+		{"0403", "6001", false},
+		{"0x", "", true},
+	}
+	for _, test := range tests {
+		if got := sameHexID(test.a, test.b); got != test.want {
+			t.Errorf("sameHexID(%q, %q) = %t, want %t", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestPickPort(t *testing.T) {
+	ports := []*enumerator.PortDetails{
+		{Name: "/dev/ttyS0"},
+		{Name: "/dev/ttyUSB0", IsUSB: true, VID: "0403", PID: "6001", SerialNumber: "A10K3XYZ"},
+		{Name: "/dev/ttyUSB1", IsUSB: true, VID: "0403", PID: "6001", SerialNumber: "B20L4ABC"},
+		{Name: "/dev/ttyUSB2", IsUSB: true, VID: "067B", PID: "2303"},
+	}
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+		err    string
+	}{
+		{"vid and pid", Config{USBVendorID: "0x067b", USBProductID: "2303"}, "/dev/ttyUSB2", ""},
+		{"serial number", Config{SerialNumber: "b20l4abc"}, "/dev/ttyUSB1", ""},
+		{"vid and serial number", Config{USBVendorID: "0403", SerialNumber: "A10K3XYZ"}, "/dev/ttyUSB0", ""},
+		{"device with vid", Config{Device: "/dev/ttyUSB1", USBVendorID: "0403"}, "/dev/ttyUSB1", ""},
+		{"ambiguous", Config{USBVendorID: "0403", USBProductID: "6001"}, "", "2 serial ports match usb_vid=0403, usb_pid=6001"},
+		{"no match", Config{USBVendorID: "10c4"}, "", "no serial port matches usb_vid=10c4"},
+		{"device without usb", Config{Device: "/dev/ttyS0", SerialNumber: "A10K3XYZ"}, "", "no serial port matches"},
+	}
+	for _, test := range tests {
+		name, err := pickPort(test.config, ports)
+		switch {
+		case test.err == "" && (err != nil || name != test.want):
+			t.Errorf("%s: pickPort = %q, %v, want %q", test.name, name, err, test.want)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: pickPort = %q, %v, want an error containing %q", test.name, name, err, test.err)
+		}
+	}
+
+	// A lone adapter is picked without any criteria
+	if name, err := pickPort(Config{}, ports[1:2]); err != nil || name != "/dev/ttyUSB0" {
+		t.Errorf("pickPort with one port = %q, %v, want /dev/ttyUSB0", name, err)
+	}
+}
@@ -0,0 +1,196 @@
+package serialhandler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrClosed is returned for commands sent after the port was closed
+var ErrClosed = errors.New("port closed")
+
+// request is a command waiting in the queue together with the channel its result is returned on
+type request struct {
+	command string
+	ack     AckConfig
+	reply   chan response
+}
+
+type response struct {
+	text string
+	err  error
+}
+
+// Send queues the command behind any other pending commands and returns the
+// reply line that acknowledged it
+func (p *Port) Send(command string) (string, error) {
+	return p.SendWithAck(command, AckConfig{})
+}
+
+// SendWithAck is Send with acknowledgment rules for this command, they take
+// precedence over the "ack" block and command_acks takes precedence over them
+func (p *Port) SendWithAck(command string, defaults AckConfig) (string, error) {
+	req := &request{command: command, ack: defaults, reply: make(chan response, 1)}
+
+	select {
+	case p.queue <- req:
+	case <-p.done:
+		return "", ErrClosed
+	}
+
+	select {
+	case res := <-req.reply:
+		return res.text, res.err
+	case <-p.done:
+		return "", ErrClosed
+	}
+}
+
+// processQueue is the only goroutine that writes to the serial port, so a
+// reply can always be matched to the command that caused it
+func (p *Port) processQueue() {
+	for {
+		select {
+		case <-p.done:
+			return
+		case req := <-p.queue:
+			text, err := p.execute(req.command, req.ack)
+			req.reply <- response{text: text, err: err}
+		}
+	}
+}
+
+// execute writes one command and waits for the reply that acknowledges or rejects it
+func (p *Port) execute(command string, defaults AckConfig) (string, error) {
+	matcher, err := p.ackFor(command, defaults)
+	if err != nil {
+		return "", err
+	}
+
+	conn := p.current()
+	if conn == nil {
+		return "", ErrOffline
+	}
+
+	// Anything still buffered belongs to an earlier exchange
+	signal := make(chan struct{}, 1)
+	p.mu.Lock()
+	stale := p.rx
+	p.rx = ""
+	p.pending = signal
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.pending = nil
+		p.mu.Unlock()
+	}()
+	if stale != "" {
+		log.Printf("Discarding unacknowledged data: %q", stale)
+	}
+
+	data, binary, err := encodeCommand(command)
+	if err != nil {
+		return "", err
+	}
+	if !binary {
+		data = append(data, matcher.writeTerminator...)
+	}
+
+	if _, err := conn.Write(data); err != nil {
+		log.Printf("Failed to write to switcher: %v", err)
+		p.connectionLost(conn, err)
+		return "", fmt.Errorf("%w: %v", ErrOffline, err)
+	}
+	log.Printf("Command sent to %s: %s", p.Status().Device, command)
+
+	timer := time.NewTimer(matcher.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-signal:
+			p.mu.Lock()
+			var lines []string
+			lines, p.rx = matcher.split(p.rx)
+			p.mu.Unlock()
+
+			for _, line := range lines {
+				log.Printf("Received: %s", line)
+				done, rejected := matcher.match(line)
+				if rejected {
+					return line, &DeviceError{Command: command, Reply: line}
+				}
+				if done {
+					return line, nil
+				}
+				// Status output that arrived in the middle of the exchange
+				p.unsolicited(line)
+			}
+		case <-timer.C:
+			log.Printf("No acknowledgment received for command: %s", command)
+			return "", fmt.Errorf("%w: %q", ErrNoAck, command)
+		case <-p.done:
+			return "", ErrClosed
+		}
+	}
+}
+
+// received buffers data from the read loop. While a command is pending it is
+// woken up to look for its reply, otherwise complete lines are unsolicited status output.
+func (p *Port) received(data string) {
+	p.mu.Lock()
+	p.rx += data
+	pending := p.pending
+	var lines []string
+	if pending == nil {
+		lines, p.rx = p.splitUnsolicited(p.rx)
+	}
+	p.mu.Unlock()
+
+	if pending != nil {
+		select {
+		case pending <- struct{}{}:
+		default:
+		}
+		return
+	}
+
+	for _, line := range lines {
+		p.unsolicited(line)
+	}
+}
+
+// OnUnsolicited registers a handler for lines that are not the reply to a command
+func (p *Port) OnUnsolicited(handler func(line string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lineHandler = handler
+}
+
+func (p *Port) unsolicited(line string) {
+	log.Printf("Unsolicited: %s", line)
+
+	p.mu.Lock()
+	handler := p.lineHandler
+	p.mu.Unlock()
+	if handler != nil {
+		handler(line)
+	}
+}
+
+// splitUnsolicited splits data that is not a reply to a command, by the frame
+// or line terminator of the driver or else config.json, the same rules as the
+// replies get from ackFor. It must be called with p.mu held.
+func (p *Port) splitUnsolicited(data string) ([]string, string) {
+	ack := p.Config.Ack.merge(p.driverAck)
+	if ack.Frame != nil {
+		if frame, err := ack.Frame.compile(); err == nil {
+			return frame.split(data)
+		}
+	}
+	if ack.Terminator == "" {
+		ack.Terminator = defaultTerminator
+	}
+	return splitLines(data, ack.Terminator)
+}
@@ -0,0 +1,43 @@
+package serialhandler
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run with -race, the queue is the only thing keeping the callers apart
+func TestConcurrentSend(t *testing.T) {
+	config := Config{Driver: "extron"}
+	for id := 1; id <= 8; id++ {
+		config.Inputs = append(config.Inputs, InputConfig{ID: id, Name: fmt.Sprintf("input%d", id)})
+	}
+	simulator := NewSimulator(config, SimulatorOptions{Delay: time.Millisecond})
+	port := NewPortWithOpener(config, simulator.Open)
+	defer port.Close()
+
+	const senders, sends = 16, 5
+	var wg sync.WaitGroup
+	errs := make(chan error, senders*sends)
+	for sender := 0; sender < senders; sender++ {
+		wg.Add(1)
+		go func(sender int) {
+			defer wg.Done()
+			for i := 0; i < sends; i++ {
+				input := (sender+i)%8 + 1
+				reply, err := port.Send(fmt.Sprintf("%d!", input))
+				if err != nil {
+					errs <- fmt.Errorf("sender %d: %w", sender, err)
+				} else if want := fmt.Sprintf("In%d All", input); reply != want {
+					errs <- fmt.Errorf("sender %d got %q, want %q", sender, reply, want)
+				}
+			}
+		}(sender)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,125 @@
+package serialhandler
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Replayer is a simulated port that plays back a capture: every time the backend
+// writes what was written in the capture, the bytes that were read after it are
+// sent back with the original timing. Writes that differ from the capture are
+// recorded as mismatches so a test can tell where the exchange diverged.
+type Replayer struct {
+	mu         sync.Mutex
+	records    []CaptureRecord
+	next       int
+	written    []byte
+	mismatches []string
+}
+
+// NewReplayer replays the records from LoadCapture
+func NewReplayer(records []CaptureRecord) *Replayer {
+	return &Replayer{records: records}
+}
+
+// Open is an Opener that connects a port to the replayer. Data the device sent
+// before the first write in the capture is sent right away.
+func (r *Replayer) Open(config Config) (Conn, string, error) {
+	port := newSimPort(r)
+
+	r.mu.Lock()
+	replies := r.replies(time.Time{})
+	r.mu.Unlock()
+
+	for _, reply := range replies {
+		reply := reply
+		time.AfterFunc(reply.delay, func() { port.reply(reply.data) })
+	}
+
+	log.Printf("Replaying %d captured records", len(r.records))
+	return port, "replay", nil
+}
+
+// Done reports whether every record in the capture has been played
+func (r *Replayer) Done() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.next >= len(r.records)
+}
+
+// Mismatches lists the writes that did not match the capture
+func (r *Replayer) Mismatches() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.mismatches...)
+}
+
+// respond compares the written data to the next captured write
+func (r *Replayer) respond(data []byte) []simReply {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.written = append(r.written, data...)
+
+	var replies []simReply
+	for r.next < len(r.records) && len(r.written) > 0 {
+		record := r.records[r.next]
+		if record.Direction != DirectionTx {
+			// The backend wrote before the device finished talking, skip ahead
+			r.next++
+			continue
+		}
+
+		expected, _ := record.Bytes()
+		if len(r.written) < len(expected) {
+			if !bytes.HasPrefix(expected, r.written) {
+				r.mismatch(expected)
+			}
+			break
+		}
+		if !bytes.Equal(r.written[:len(expected)], expected) {
+			r.mismatch(expected)
+			break
+		}
+
+		r.written = r.written[len(expected):]
+		r.next++
+		replies = append(replies, r.replies(record.Time)...)
+	}
+
+	if r.next >= len(r.records) && len(r.written) > 0 {
+		r.mismatches = append(r.mismatches, fmt.Sprintf("unexpected write after end of capture: %q", r.written))
+		r.written = nil
+	}
+	return replies
+}
+
+// replies collects the reads following the current position, delayed relative to sent.
+// Must be called with r.mu held.
+func (r *Replayer) replies(sent time.Time) []simReply {
+	var replies []simReply
+	for r.next < len(r.records) && r.records[r.next].Direction == DirectionRx {
+		record := r.records[r.next]
+		data, _ := record.Bytes()
+
+		var delay time.Duration
+		if !sent.IsZero() {
+			delay = record.Time.Sub(sent)
+		}
+		replies = append(replies, simReply{data: data, delay: delay})
+		r.next++
+	}
+	return replies
+}
+
+// mismatch records a write that differs from the capture and drops it.
+// Must be called with r.mu held.
+func (r *Replayer) mismatch(expected []byte) {
+	message := fmt.Sprintf("record %d: expected write %q, got %q", r.next, expected, r.written)
+	log.Printf("Replay mismatch, %s", message)
+	r.mismatches = append(r.mismatches, message)
+	r.written = nil
+}
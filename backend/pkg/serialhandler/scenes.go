@@ -0,0 +1,126 @@
+package serialhandler
+
+import "fmt"
+
+// Scene step types
+const (
+	StepCommand      = "command"
+	StepSelectInput  = "select_input"
+	StepPower        = "power"
+	StepWakeOnLan    = "wake_on_lan"
+	StepDelay        = "delay"
+	StepWaitForState = "wait_for_state"
+)
+
+// Step error handling, "abort" is the default
+const (
+	OnErrorAbort    = "abort"
+	OnErrorContinue = "continue"
+)
+
+// SceneConfig is a named macro of several device actions run in order
+type SceneConfig struct {
+	DisplayName string      `json:"display_name"`
+	Steps       []SceneStep `json:"steps"`
+}
+
+// SceneStep is one action of a scene. Which fields are used depends on Type:
+//   - command: Command is sent to the switcher as-is
+//   - select_input: Input is the input name, or the display's input code
+//   - power: Power is "on" or "off"
+//   - wake_on_lan: powers on the displays
+//   - delay: waits DelayMs
+//   - wait_for_state: polls the switcher until Power and/or Input match, for at most TimeoutMs
+//
+// Device names the device the step goes to, the first switcher by default
+// (every display for wake_on_lan).
+type SceneStep struct {
+	Type      string `json:"type"`
+	Device    string `json:"device,omitempty"`
+	Command   string `json:"command,omitempty"`
+	Input     string `json:"input,omitempty"`
+	Power     string `json:"power,omitempty"`
+	DelayMs   int    `json:"delay_ms,omitempty"`
+	TimeoutMs int    `json:"timeout_ms,omitempty"`
+	OnError   string `json:"on_error,omitempty"`
+}
+
+// validateScenes catches typos in scene steps at startup
+func (c Config) validateScenes() error {
+	for name, scene := range c.Scenes {
+		if len(scene.Steps) == 0 {
+			return fmt.Errorf("scene %q has no steps", name)
+		}
+		for i, step := range scene.Steps {
+			if err := c.validateStep(step); err != nil {
+				return fmt.Errorf("scene %q step %d: %w", name, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c Config) validateStep(step SceneStep) error {
+	switch step.OnError {
+	case "", OnErrorAbort, OnErrorContinue:
+	default:
+		return fmt.Errorf("invalid on_error %q", step.OnError)
+	}
+
+	deviceType := DeviceSwitcher
+	if step.Type == StepWakeOnLan {
+		deviceType = DeviceDisplay
+	}
+	device, ok := c.DeviceByName(step.Device, deviceType)
+	switch {
+	case !ok && step.Device != "":
+		return fmt.Errorf("unknown device %q", step.Device)
+	case !ok && step.Type != StepDelay:
+		return fmt.Errorf("%s step needs a %s", step.Type, deviceType)
+	}
+	if device.Type == DeviceDisplay && step.Type != StepSelectInput && step.Type != StepPower && step.Type != StepWakeOnLan {
+		return fmt.Errorf("%s step can not go to display %q", step.Type, device.Name)
+	}
+
+	switch step.Type {
+	case StepCommand:
+		if step.Command == "" {
+			return fmt.Errorf("command step without command")
+		}
+	case StepSelectInput:
+		if device.Type == DeviceDisplay {
+			if step.Input == "" {
+				return fmt.Errorf("select_input step without input")
+			}
+		} else if _, ok := device.InputByName(step.Input); !ok {
+			return fmt.Errorf("unknown input %q", step.Input)
+		}
+	case StepPower:
+		if step.Power != "on" && step.Power != "off" {
+			return fmt.Errorf("power must be on or off, got %q", step.Power)
+		}
+	case StepWakeOnLan:
+		if device.Type != DeviceDisplay {
+			return fmt.Errorf("wake_on_lan step needs a display, %q is a %s", device.Name, device.Type)
+		}
+	case StepDelay:
+		if step.DelayMs <= 0 {
+			return fmt.Errorf("delay step without delay_ms")
+		}
+	case StepWaitForState:
+		if step.Power == "" && step.Input == "" {
+			return fmt.Errorf("wait_for_state step needs power or input")
+		}
+		if step.Power != "" && step.Power != PowerStateOn && step.Power != PowerStateStandby {
+			return fmt.Errorf("power must be %s or %s, got %q", PowerStateOn, PowerStateStandby, step.Power)
+		}
+		if step.Input != "" {
+			if _, ok := device.InputByName(step.Input); !ok {
+				return fmt.Errorf("unknown input %q", step.Input)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown step type %q", step.Type)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package serialhandler
+
+import "fmt"
+
+// Device groups for shutdown steps
+const (
+	ShutdownDisplays  = "displays"
+	ShutdownSwitchers = "switchers"
+)
+
+// ShutdownStep is one device, or group of devices, of the room shutdown
+type ShutdownStep struct {
+	// Device is a device name, "displays" or "switchers"
+	Device string `json:"device"`
+	// DelayMs is how long to wait after this step before the next one
+	DelayMs int `json:"delay_ms"`
+}
+
+// ShutdownSteps returns the configured shutdown order. Without a "shutdown"
+// list the displays are turned off first, while a switcher can still send
+// them CEC commands, then the switchers.
+func (c Config) ShutdownSteps() []ShutdownStep {
+	if len(c.Shutdown) > 0 {
+		return c.Shutdown
+	}
+	return []ShutdownStep{{Device: ShutdownDisplays}, {Device: ShutdownSwitchers}}
+}
+
+func (c Config) validateShutdown() error {
+	for i, step := range c.Shutdown {
+		switch step.Device {
+		case ShutdownDisplays, ShutdownSwitchers:
+		default:
+			if _, ok := c.DeviceByName(step.Device, ""); !ok || step.Device == "" {
+				return fmt.Errorf("shutdown step %d: unknown device %q", i+1, step.Device)
+			}
+		}
+		if step.DelayMs < 0 {
+			return fmt.Errorf("shutdown step %d: negative delay_ms", i+1)
+		}
+	}
+	return nil
+}
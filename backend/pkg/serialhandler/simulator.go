@@ -0,0 +1,490 @@
+package serialhandler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// SimulatorOptions are the faults the simulated switcher injects
+type SimulatorOptions struct {
+	// Delay is added before every reply
+	Delay time.Duration
+	// ErrorRate is the fraction of commands answered with an error reply
+	ErrorRate float64
+	// DropRate is the fraction of commands that get no reply at all
+	DropRate float64
+}
+
+// Simulator is a virtual HDMI switcher that understands the commands from
+// inputs, labeled_commands, startup_commands and scene commands and replies like the real device
+// ("<command> Command OK" / "Command incorrect"). The state_query commands are
+// answered with "Input: port NN Output: ON|OFF". With the "extron" driver it
+// speaks SIS instead, with "kramer" Protocol 3000. Its Open method is an Opener,
+// so a Port can be run against it without any hardware.
+type Simulator struct {
+	config Config
+
+	mu       sync.Mutex
+	options  SimulatorOptions
+	state    State
+	failNext int
+	offline  bool
+	input    string
+	port     *simPort
+}
+
+// NewSimulator returns a powered on simulator on input 1
+func NewSimulator(config Config, options SimulatorOptions) *Simulator {
+	return &Simulator{
+		config:  config,
+		options: options,
+		state:   State{Input: 1, Power: PowerStateOn},
+	}
+}
+
+// Open connects a new in-memory serial port to the simulator
+func (s *Simulator) Open(config Config) (Conn, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.offline {
+		return nil, "", errors.New("simulated switcher is unplugged")
+	}
+
+	s.input = ""
+	s.port = newSimPort(s)
+	log.Println("Connected to simulated switcher")
+	return s.port, "simulator", nil
+}
+
+// State returns the input and power state the simulator is in
+func (s *Simulator) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// SetOptions changes the injected faults
+func (s *Simulator) SetOptions(options SimulatorOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.options = options
+}
+
+// FailNext makes the next n commands get an error reply
+func (s *Simulator) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failNext = n
+}
+
+// Unplug breaks the open port and makes reconnects fail until Replug is called
+func (s *Simulator) Unplug() {
+	s.mu.Lock()
+	port := s.port
+	s.offline = true
+	s.port = nil
+	s.mu.Unlock()
+
+	if port != nil {
+		port.fail(errors.New("simulated switcher unplugged"))
+	}
+}
+
+// Replug lets the port reconnect after Unplug
+func (s *Simulator) Replug() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offline = false
+}
+
+// handle executes one command and returns the reply line, or "" to stay silent
+func (s *Simulator) handle(command string) (string, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delay := s.options.Delay
+	if s.options.DropRate > 0 && rand.Float64() < s.options.DropRate {
+		return "", delay
+	}
+	if s.failNext > 0 {
+		s.failNext--
+		return s.errorReply(), delay
+	}
+	if s.options.ErrorRate > 0 && rand.Float64() < s.options.ErrorRate {
+		return s.errorReply(), delay
+	}
+
+	switch s.config.Driver {
+	case "extron":
+		return s.handleExtron(command), delay
+	case "kramer":
+		return s.handleKramer(command), delay
+	default:
+		return s.handleGeneric(command), delay
+	}
+}
+
+// handleGeneric answers the free-form commands from config.json, must be called with s.mu held
+func (s *Simulator) handleGeneric(command string) string {
+	for _, input := range s.config.InputList() {
+		if input.Command == command {
+			s.state.Input = input.ID
+			return command + " Command OK"
+		}
+	}
+
+	switch command {
+	case s.config.LabeledCommands["turn_on"]:
+		s.state.Power = PowerStateOn
+		return command + " Command OK"
+	case s.config.LabeledCommands["turn_off"]:
+		s.state.Power = PowerStateStandby
+		return command + " Command OK"
+	}
+
+	for _, query := range s.config.StateQuery.Commands {
+		if query == command {
+			return s.statusLine()
+		}
+	}
+
+	for _, startup := range s.config.StartupCommands {
+		if startup == command {
+			return command + " Command OK"
+		}
+	}
+
+	for _, scene := range s.config.Scenes {
+		for _, step := range scene.Steps {
+			if step.Type == StepCommand && step.Command == command {
+				return command + " Command OK"
+			}
+		}
+	}
+
+	return s.errorReply()
+}
+
+// PressRemote switches input like the hardware remote would, the switcher
+// announces the change with an unsolicited status line
+func (s *Simulator) PressRemote(input int) {
+	s.mu.Lock()
+	s.state.Input = input
+	line := s.statusLine()
+	port := s.port
+	s.mu.Unlock()
+
+	if port != nil {
+		port.reply([]byte(line + "\r\n"))
+	}
+}
+
+// errorReply is what the device answers to commands it rejects, must be called with s.mu held
+func (s *Simulator) errorReply() string {
+	switch s.config.Driver {
+	case "extron":
+		return "E10"
+	case "kramer":
+		return "~01@ ERR 002"
+	}
+	return "Command incorrect"
+}
+
+// statusLine is the reply to a state query and what the switcher announces
+// when the remote is used, must be called with s.mu held
+func (s *Simulator) statusLine() string {
+	switch s.config.Driver {
+	case "extron":
+		return fmt.Sprintf("In%d All", s.state.Input)
+	case "kramer":
+		return fmt.Sprintf("~01@ROUTE 1,1,%d", s.state.Input)
+	}
+
+	output := "ON"
+	if s.state.Power == PowerStateStandby {
+		output = "OFF"
+	}
+	return fmt.Sprintf("Input: port %02d Output: %s", s.state.Input, output)
+}
+
+// responder produces the replies of a simulated device to the data written to it
+type responder interface {
+	respond(data []byte) []simReply
+}
+
+// simReply is data the simulated device sends after the given delay
+type simReply struct {
+	data  []byte
+	delay time.Duration
+}
+
+// respond splits the written data into commands and answers each of them
+func (s *Simulator) respond(data []byte) []simReply {
+	s.mu.Lock()
+	s.input += string(data)
+	// SIS commands end in their command character, everything else in a line break
+	separators := "\r\n"
+	if s.config.Driver == "extron" {
+		separators = "!BQ"
+	}
+	var commands []string
+	for {
+		index := strings.IndexAny(s.input, separators)
+		if index == -1 {
+			break
+		}
+		if separators == "\r\n" {
+			commands = append(commands, s.input[:index])
+		} else {
+			commands = append(commands, s.input[:index+1])
+		}
+		s.input = s.input[index+1:]
+	}
+	s.mu.Unlock()
+
+	var replies []simReply
+	for _, command := range commands {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+		reply, delay := s.handle(command)
+		if reply != "" {
+			replies = append(replies, simReply{data: []byte(reply + "\r\n"), delay: delay})
+		}
+	}
+	return replies
+}
+
+// simPort is an in-memory serial.Port connected to a simulated device
+type simPort struct {
+	device responder
+
+	mu          sync.Mutex
+	output      []byte
+	readTimeout time.Duration
+	err         error
+	ready       chan struct{}
+}
+
+func newSimPort(device responder) *simPort {
+	return &simPort{device: device, readTimeout: serial.NoTimeout, ready: make(chan struct{}, 1)}
+}
+
+// Write hands the data to the device and schedules its replies
+func (p *simPort) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	err := p.err
+	p.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, reply := range p.device.respond(data) {
+		reply := reply
+		if reply.delay > 0 {
+			time.AfterFunc(reply.delay, func() { p.reply(reply.data) })
+		} else {
+			p.reply(reply.data)
+		}
+	}
+	return len(data), nil
+}
+
+func (p *simPort) reply(data []byte) {
+	p.mu.Lock()
+	p.output = append(p.output, data...)
+	p.mu.Unlock()
+	p.notify()
+}
+
+// Read blocks until a reply is available or the read timeout expires
+func (p *simPort) Read(buffer []byte) (int, error) {
+	p.mu.Lock()
+	timeout := p.readTimeout
+	p.mu.Unlock()
+
+	var expired <-chan time.Time
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	for {
+		p.mu.Lock()
+		if p.err != nil {
+			p.mu.Unlock()
+			return 0, p.err
+		}
+		if len(p.output) > 0 {
+			n := copy(buffer, p.output)
+			p.output = p.output[n:]
+			p.mu.Unlock()
+			return n, nil
+		}
+		p.mu.Unlock()
+
+		select {
+		case <-p.ready:
+		case <-expired:
+			return 0, nil
+		}
+	}
+}
+
+func (p *simPort) fail(err error) {
+	p.mu.Lock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.mu.Unlock()
+	p.notify()
+}
+
+func (p *simPort) notify() {
+	select {
+	case p.ready <- struct{}{}:
+	default:
+	}
+}
+
+func (p *simPort) Close() error {
+	p.fail(ErrClosed)
+	return nil
+}
+
+func (p *simPort) SetReadTimeout(timeout time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readTimeout = timeout
+	return nil
+}
+
+func (p *simPort) GetModemStatusBits() (*serial.ModemStatusBits, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &serial.ModemStatusBits{CTS: true, DSR: true}, nil
+}
+
+func (p *simPort) SetMode(mode *serial.Mode) error    { return nil }
+func (p *simPort) Drain() error                       { return nil }
+func (p *simPort) ResetInputBuffer() error            { return nil }
+func (p *simPort) ResetOutputBuffer() error           { return nil }
+func (p *simPort) SetDTR(dtr bool) error              { return nil }
+func (p *simPort) SetRTS(rts bool) error              { return nil }
+func (p *simPort) Break(duration time.Duration) error { return nil }
+
+// handleExtron answers the SIS commands used by the extron driver, must be called with s.mu held
+func (s *Simulator) handleExtron(command string) string {
+	argument, function := command[:len(command)-1], command[len(command)-1:]
+
+	switch {
+	case function == "!" && argument == "":
+		return strconv.Itoa(s.state.Input)
+	case function == "!":
+		input, err := strconv.Atoi(argument)
+		if err != nil {
+			return "E13"
+		}
+		if _, ok := s.config.InputByID(input); !ok {
+			return "E01"
+		}
+		s.state.Input = input
+		return fmt.Sprintf("In%d All", input)
+	case function == "B" && argument == "":
+		if s.state.Power == PowerStateStandby {
+			return "1"
+		}
+		return "0"
+	case function == "B" && argument == "0":
+		s.state.Power = PowerStateOn
+		return "Vmt0"
+	case function == "B" && argument == "1":
+		s.state.Power = PowerStateStandby
+		return "Vmt1"
+	case function == "Q" && argument == "":
+		return "1.00"
+	default:
+		return "E10"
+	}
+}
+
+// kramerRequest splits "#01@ROUTE 1,1,2" into the command and its parameters
+var kramerRequest = regexp.MustCompile(`^#(?:\d+@)?\s*([A-Za-z?]+)\s*(.*)$`)
+
+// handleKramer answers the Protocol 3000 commands used by the kramer driver, must be called with s.mu held
+func (s *Simulator) handleKramer(request string) string {
+	match := kramerRequest.FindStringSubmatch(request)
+	if match == nil {
+		return "~01@ ERR 001"
+	}
+	command, params := strings.ToUpper(match[1]), strings.Split(match[2], ",")
+
+	reply := func(params string, ok bool) string {
+		line := fmt.Sprintf("~%02d@%s %s", max(s.config.Kramer.MachineID, 1), strings.TrimSuffix(command, "?"), params)
+		if ok {
+			line += " OK"
+		}
+		return line
+	}
+	fail := func(code string) string {
+		return fmt.Sprintf("~%02d@%s ERR %s", max(s.config.Kramer.MachineID, 1), command, code)
+	}
+	flag := func(standby bool) string {
+		if standby {
+			return "1"
+		}
+		return "0"
+	}
+
+	switch {
+	case command == "ROUTE" && len(params) == 3:
+		input, err := strconv.Atoi(params[2])
+		if err != nil {
+			return fail("001")
+		}
+		if _, ok := s.config.InputByID(input); !ok {
+			return fail("003")
+		}
+		s.state.Input = input
+		return reply(match[2], true)
+	case command == "ROUTE?" && len(params) == 2:
+		return reply(fmt.Sprintf("1,%s,%d", params[1], s.state.Input), false)
+	case (command == "VMUTE" && len(params) == 2) || (command == "STANDBY" && len(params) == 1):
+		switch params[len(params)-1] {
+		case "0":
+			s.state.Power = PowerStateOn
+		case "1":
+			s.state.Power = PowerStateStandby
+		default:
+			return fail("003")
+		}
+		return reply(match[2], true)
+	case command == "VMUTE?" && len(params) == 1:
+		return reply(fmt.Sprintf("%s,%s", params[0], flag(s.state.Power == PowerStateStandby)), false)
+	case command == "STANDBY?":
+		return reply(flag(s.state.Power == PowerStateStandby), false)
+	case command == "SIGNAL?" && len(params) == 1:
+		input, err := strconv.Atoi(params[0])
+		if err != nil {
+			return fail("001")
+		}
+		return reply(fmt.Sprintf("%d,%s", input, flag(input == s.state.Input)), false)
+	default:
+		return fail("002")
+	}
+}
@@ -0,0 +1,113 @@
+package serialhandler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func genericTestConfig() Config {
+	return Config{
+		LabeledCommands: map[string]string{"turn_on": "standby off", "turn_off": "standby on"},
+		Inputs: []InputConfig{
+			{ID: 1, Name: "wireless", Command: "sw i01"},
+			{ID: 2, Name: "room_pc", Command: "sw i02"},
+		},
+		Ack: AckConfig{ErrorPattern: "(?i)command incorrect", TimeoutMs: 500},
+		StateQuery: StateQueryConfig{
+			Commands:       []string{"read"},
+			InputPattern:   `(?i)input:\s*port\s*(\d+)`,
+			PowerOnPattern: `(?i)output:\s*on`,
+			StandbyPattern: `(?i)output:\s*off`,
+		},
+	}
+}
+
+// waitFor polls condition until it holds or a second has passed
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSimulatorTracksState(t *testing.T) {
+	driver, simulator := newSimulatedDriver(t, genericTestConfig())
+
+	if err := driver.SelectInput(2); err != nil {
+		t.Fatalf("SelectInput: %v", err)
+	}
+	if err := driver.PowerOff(); err != nil {
+		t.Fatalf("PowerOff: %v", err)
+	}
+	state, err := driver.QueryState()
+	if err != nil {
+		t.Fatalf("QueryState: %v", err)
+	}
+	if state.Input != 2 || state.Power != PowerStateStandby {
+		t.Errorf("QueryState = %+v, want input 2 in standby", state)
+	}
+
+	simulator.PressRemote(1)
+	waitFor(t, "the remote change", func() bool { return driver.(*genericDriver).current().Input == 1 })
+}
+
+func TestSimulatorFaults(t *testing.T) {
+	driver, simulator := newSimulatedDriver(t, genericTestConfig())
+
+	simulator.FailNext(1)
+	if err := driver.SelectInput(1); !errors.Is(err, ErrDeviceRejected) {
+		t.Errorf("SelectInput with FailNext = %v, want ErrDeviceRejected", err)
+	}
+	if err := driver.SelectInput(1); err != nil {
+		t.Errorf("SelectInput after the failure: %v", err)
+	}
+
+	simulator.SetOptions(SimulatorOptions{Delay: 50 * time.Millisecond})
+	start := time.Now()
+	if err := driver.PowerOn(); err != nil || time.Since(start) < 50*time.Millisecond {
+		t.Errorf("PowerOn with delay = %v after %s", err, time.Since(start))
+	}
+}
+
+func TestSimulatorUnplug(t *testing.T) {
+	config := genericTestConfig()
+	simulator := NewSimulator(config, SimulatorOptions{})
+	port := NewPortWithOpener(config, simulator.Open)
+	defer port.Close()
+
+	simulator.Unplug()
+	waitFor(t, "the port to go offline", func() bool { return !port.Connected() })
+	if err := port.Write("sw i01"); !errors.Is(err, ErrOffline) {
+		t.Errorf("Write while unplugged = %v, want ErrOffline", err)
+	}
+
+	simulator.Replug()
+	deadline := time.Now().Add(3 * time.Second)
+	for !port.Connected() {
+		if time.Now().After(deadline) {
+			t.Fatal("port did not reconnect after Replug")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := port.Write("sw i01"); err != nil {
+		t.Errorf("Write after Replug: %v", err)
+	}
+}
+
+func TestGenericDriverWithoutPowerCommand(t *testing.T) {
+	config := genericTestConfig()
+	delete(config.LabeledCommands, "turn_off")
+	driver, _ := newSimulatedDriver(t, config)
+
+	if err := driver.PowerOff(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("PowerOff error = %v, want ErrUnsupported", err)
+	}
+	if err := driver.PowerOn(); err != nil {
+		t.Errorf("PowerOn: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package serialhandler
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// Connection states reported by Port.Status
+const (
+	StateConnecting = "connecting"
+	StateConnected  = "connected"
+	StateOffline    = "offline"
+	StateClosed     = "closed"
+)
+
+// ErrOffline is returned for commands sent while the switcher is disconnected
+var ErrOffline = errors.New("switcher offline")
+
+const (
+	minReconnectDelay   = 1 * time.Second
+	maxReconnectDelay   = 30 * time.Second
+	healthCheckInterval = 2 * time.Second
+)
+
+// ConnectionStatus describes the serial link for the API
+type ConnectionStatus struct {
+	State     string    `json:"state"`
+	Device    string    `json:"device,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+	Since     time.Time `json:"since"`
+}
+
+// Status returns the current connection state of the port
+func (p *Port) Status() ConnectionStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status
+}
+
+// Connected reports whether commands can currently be sent
+func (p *Port) Connected() bool {
+	return p.Status().State == StateConnected
+}
+
+// supervise reopens the port with exponential backoff whenever it is lost
+func (p *Port) supervise() {
+	delay := p.minReconnectDelay
+
+	for {
+		if !p.Connected() {
+			select {
+			case <-p.done:
+				return
+			case <-time.After(delay):
+			}
+
+			if err := p.connect(); err != nil {
+				delay *= 2
+				if delay > p.maxReconnectDelay {
+					delay = p.maxReconnectDelay
+				}
+				log.Printf("Reconnect failed, retrying in %s: %v", delay, err)
+				continue
+			}
+			delay = p.minReconnectDelay
+		}
+
+		select {
+		case <-p.done:
+			return
+		case <-p.lost:
+		case <-time.After(healthCheckInterval):
+			p.checkHealth()
+		}
+	}
+}
+
+// connect opens the port and replays the startup commands on it
+func (p *Port) connect() error {
+	select {
+	case <-p.done:
+		return ErrClosed
+	default:
+	}
+
+	conn, name, err := p.open(p.Config)
+	if err != nil {
+		p.mu.Lock()
+		p.setStatus(StateOffline, err)
+		p.mu.Unlock()
+		return err
+	}
+
+	p.mu.Lock()
+	select {
+	case <-p.done:
+		// Closed while we were opening
+		p.mu.Unlock()
+		conn.Close()
+		return ErrClosed
+	default:
+	}
+	p.conn = conn
+	p.name = name
+	p.setStatus(StateConnected, nil)
+	p.mu.Unlock()
+	log.Printf("Switcher connected on %s", name)
+	go p.readLoop(conn)
+
+	log.Println("Running startup commands...")
+	p.RunStartupCommands(p.Config.StartupCommands)
+	return nil
+}
+
+// healthChecker is implemented by serial ports, reading the modem status bits
+// fails once a USB adapter is unplugged even if nothing has been sent since.
+// Network connections notice a dropped socket in the read loop instead.
+type healthChecker interface {
+	GetModemStatusBits() (*serial.ModemStatusBits, error)
+}
+
+func (p *Port) checkHealth() {
+	conn := p.current()
+	checker, ok := conn.(healthChecker)
+	if !ok {
+		return
+	}
+	if _, err := checker.GetModemStatusBits(); err != nil {
+		p.connectionLost(conn, err)
+	}
+}
+
+// connectionLost closes a connection that failed to read or write and wakes the supervisor.
+// It is a no-op if the supervisor already replaced that port.
+func (p *Port) connectionLost(conn Conn, err error) {
+	p.mu.Lock()
+	if p.conn != conn {
+		p.mu.Unlock()
+		return
+	}
+	p.conn.Close()
+	p.conn = nil
+	p.setStatus(StateOffline, err)
+	p.mu.Unlock()
+
+	log.Printf("Switcher connection lost: %v", err)
+	select {
+	case p.lost <- struct{}{}:
+	default:
+	}
+}
+
+// current returns the open connection or nil while offline
+func (p *Port) current() Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.conn
+}
+
+// setStatus must be called with p.mu held
+func (p *Port) setStatus(state string, err error) {
+	if p.status.State != state {
+		p.status.Since = time.Now()
+	}
+	p.status.State = state
+	p.status.Device = p.name
+	p.status.LastError = ""
+	if err != nil {
+		p.status.LastError = err.Error()
+	}
+}
@@ -0,0 +1,96 @@
+package serialhandler
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newFastPort is NewPortWithOpener with reconnect delays short enough for the tests
+func newFastPort(config Config, open Opener) *Port {
+	port := newPort(config, open)
+	port.minReconnectDelay, port.maxReconnectDelay = 20*time.Millisecond, 80*time.Millisecond
+	port.start()
+	return port
+}
+
+// attempts records when the supervisor tried to open the simulator
+type attempts struct {
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (a *attempts) opener(simulator *Simulator) Opener {
+	return func(config Config) (Conn, string, error) {
+		a.mu.Lock()
+		a.times = append(a.times, time.Now())
+		a.mu.Unlock()
+		return simulator.Open(config)
+	}
+}
+
+func (a *attempts) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.times)
+}
+
+func TestSupervisorReconnectsWithBackoff(t *testing.T) {
+	config := genericTestConfig()
+	simulator := NewSimulator(config, SimulatorOptions{})
+	var opened attempts
+	port := newFastPort(config, opened.opener(simulator))
+	defer port.Close()
+	if status := port.Status(); status.State != StateConnected || status.Device != "simulator" {
+		t.Fatalf("status after NewPort = %+v, want connected to the simulator", status)
+	}
+
+	simulator.Unplug()
+	waitFor(t, "the port to go offline", func() bool { return port.Status().State == StateOffline })
+	if status := port.Status(); !strings.Contains(status.LastError, "unplugged") {
+		t.Errorf("offline status = %+v, want the unplug error", status)
+	}
+	waitFor(t, "five reconnect attempts", func() bool { return opened.count() >= 6 })
+
+	opened.mu.Lock()
+	times := append([]time.Time(nil), opened.times[1:]...)
+	opened.mu.Unlock()
+	// 20ms doubles to 40ms and 80ms, then stays at the 80ms maximum
+	minimums := []time.Duration{40, 80, 80, 80}
+	for i, minimum := range minimums {
+		gap := times[i+1].Sub(times[i])
+		if gap < minimum*time.Millisecond || gap > 4*port.maxReconnectDelay {
+			t.Errorf("attempt %d came %v after the previous one, want at least %dms and capped", i+2, gap, minimum)
+		}
+	}
+
+	offlineSince := port.Status().Since
+	simulator.Replug()
+	waitFor(t, "the port to reconnect", port.Connected)
+	if status := port.Status(); status.LastError != "" || !status.Since.After(offlineSince) {
+		t.Errorf("status after Replug = %+v, want connected without an error", status)
+	}
+	if err := port.Write("sw i02"); err != nil {
+		t.Errorf("Write after Replug: %v", err)
+	}
+}
+
+func TestSupervisorReplaysStartupCommands(t *testing.T) {
+	config := genericTestConfig()
+	config.StartupCommands = []string{"sw i02"}
+	simulator := NewSimulator(config, SimulatorOptions{})
+	port := newFastPort(config, simulator.Open)
+	defer port.Close()
+	if input := simulator.State().Input; input != 2 {
+		t.Fatalf("input after connecting = %d, want 2 from the startup commands", input)
+	}
+
+	simulator.PressRemote(1)
+	simulator.Unplug()
+	waitFor(t, "the port to go offline", func() bool { return !port.Connected() })
+	simulator.Replug()
+	waitFor(t, "the startup commands after reconnecting", func() bool {
+		return port.Connected() && simulator.State().Input == 2
+	})
+}
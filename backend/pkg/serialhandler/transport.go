@@ -0,0 +1,308 @@
+package serialhandler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Conn is the connection to a device, a serial port or a network socket
+type Conn interface {
+	io.ReadWriteCloser
+}
+
+// Transport types
+const (
+	TransportSerial = "serial"
+	TransportTCP    = "tcp"
+	TransportTelnet = "telnet"
+)
+
+const (
+	defaultConnectTimeout = 5 * time.Second
+	defaultTelnetPort     = 23
+	defaultLoginPrompt    = `(?i)(login|user\s*name|user)\s*:\s*$`
+	defaultPasswordPrompt = `(?i)password\s*:\s*$`
+)
+
+// TransportConfig selects how the device is reached. Serial devices use the
+// port settings at the top level of the config.
+type TransportConfig struct {
+	Type string `json:"type"`
+	Host string `json:"host"`
+	Port int    `json:"port"`
+	// Telnet login, the prompts default to "login:"/"username:" and "password:"
+	Username         string `json:"username"`
+	Password         string `json:"password"`
+	LoginPrompt      string `json:"login_prompt"`
+	PasswordPrompt   string `json:"password_prompt"`
+	ConnectTimeoutMs int    `json:"connect_timeout_ms"`
+}
+
+// OpenTransport is the default Opener, it connects over the transport in config.Transport
+func OpenTransport(config Config) (Conn, string, error) {
+	switch config.Transport.Type {
+	case "", TransportSerial:
+		return OpenSerial(config)
+	case TransportTCP:
+		return openTCP(config.Transport)
+	case TransportTelnet:
+		return openTelnet(config.Transport)
+	default:
+		return nil, "", fmt.Errorf("unknown transport %q", config.Transport.Type)
+	}
+}
+
+func (t TransportConfig) validate() error {
+	switch t.Type {
+	case "", TransportSerial:
+		return nil
+	case TransportTCP:
+		if t.Port == 0 {
+			return errors.New("tcp transport needs a port")
+		}
+	case TransportTelnet:
+	default:
+		return fmt.Errorf("unknown transport %q", t.Type)
+	}
+
+	if t.Host == "" {
+		return fmt.Errorf("%s transport needs a host", t.Type)
+	}
+	if _, err := regexp.Compile(t.LoginPrompt); err != nil {
+		return fmt.Errorf("invalid login_prompt: %w", err)
+	}
+	if _, err := regexp.Compile(t.PasswordPrompt); err != nil {
+		return fmt.Errorf("invalid password_prompt: %w", err)
+	}
+	return nil
+}
+
+func (t TransportConfig) connectTimeout() time.Duration {
+	if t.ConnectTimeoutMs > 0 {
+		return time.Duration(t.ConnectTimeoutMs) * time.Millisecond
+	}
+	return defaultConnectTimeout
+}
+
+func dial(t TransportConfig, defaultPort int) (net.Conn, string, error) {
+	port := t.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	address := net.JoinHostPort(t.Host, strconv.Itoa(port))
+
+	log.Printf("Connecting to %s://%s", t.Type, address)
+	conn, err := net.DialTimeout("tcp", address, t.connectTimeout())
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Notice a device that lost power without closing the socket
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetKeepAlive(true)
+		tcpConn.SetKeepAlivePeriod(15 * time.Second)
+	}
+	return conn, fmt.Sprintf("%s://%s", t.Type, address), nil
+}
+
+// openTCP connects to a raw TCP port, commands and replies are sent as-is
+func openTCP(t TransportConfig) (Conn, string, error) {
+	return dial(t, 0)
+}
+
+// openTelnet connects to a Telnet port and logs in when credentials are configured
+func openTelnet(t TransportConfig) (Conn, string, error) {
+	netConn, name, err := dial(t, defaultTelnetPort)
+	if err != nil {
+		return nil, "", err
+	}
+
+	conn := &telnetConn{Conn: netConn}
+	if t.Username != "" || t.Password != "" {
+		if err := conn.login(t); err != nil {
+			netConn.Close()
+			return nil, "", fmt.Errorf("telnet login to %s failed: %w", name, err)
+		}
+	}
+	return conn, name, nil
+}
+
+// Telnet protocol bytes
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWill = 251
+	telnetWont = 252
+	telnetDo   = 253
+	telnetDont = 254
+	telnetIAC  = 255
+
+	telnetOptionEcho            = 1
+	telnetOptionSuppressGoAhead = 3
+)
+
+// telnetConn strips the Telnet negotiation from what is read and answers it.
+// The server may echo and suppress go-ahead, every other option is refused.
+type telnetConn struct {
+	net.Conn
+
+	// state of the IAC parser, kept across reads since a sequence can be split
+	state   int
+	command byte
+}
+
+const (
+	telnetData = iota
+	telnetCommand
+	telnetOption
+	telnetSubnegotiation
+	telnetSubnegotiationIAC
+)
+
+func (c *telnetConn) Read(buffer []byte) (int, error) {
+	for {
+		raw := make([]byte, len(buffer))
+		n, err := c.Conn.Read(raw)
+
+		data, reply := c.filter(raw[:n])
+		if len(reply) > 0 {
+			if _, writeErr := c.Conn.Write(reply); writeErr != nil && err == nil {
+				err = writeErr
+			}
+		}
+		// Keep reading if the chunk was only negotiation, callers treat 0 bytes as a timeout
+		if len(data) > 0 || err != nil {
+			return copy(buffer, data), err
+		}
+	}
+}
+
+// Write escapes 0xFF bytes in the data
+func (c *telnetConn) Write(data []byte) (int, error) {
+	escaped := make([]byte, 0, len(data))
+	for _, b := range data {
+		escaped = append(escaped, b)
+		if b == telnetIAC {
+			escaped = append(escaped, telnetIAC)
+		}
+	}
+	if _, err := c.Conn.Write(escaped); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// filter returns the data bytes of raw and the negotiation replies to send
+func (c *telnetConn) filter(raw []byte) (data, reply []byte) {
+	for _, b := range raw {
+		switch c.state {
+		case telnetData:
+			if b == telnetIAC {
+				c.state = telnetCommand
+			} else {
+				data = append(data, b)
+			}
+		case telnetCommand:
+			switch b {
+			case telnetIAC:
+				data = append(data, telnetIAC)
+				c.state = telnetData
+			case telnetWill, telnetWont, telnetDo, telnetDont:
+				c.command = b
+				c.state = telnetOption
+			case telnetSB:
+				c.state = telnetSubnegotiation
+			default:
+				// NOP, GA, AYT and friends carry no option
+				c.state = telnetData
+			}
+		case telnetOption:
+			reply = append(reply, c.negotiate(c.command, b)...)
+			c.state = telnetData
+		case telnetSubnegotiation:
+			if b == telnetIAC {
+				c.state = telnetSubnegotiationIAC
+			}
+		case telnetSubnegotiationIAC:
+			if b == telnetSE {
+				c.state = telnetData
+			} else {
+				c.state = telnetSubnegotiation
+			}
+		}
+	}
+	return data, reply
+}
+
+// negotiate answers a WILL/WONT/DO/DONT for an option
+func (c *telnetConn) negotiate(command, option byte) []byte {
+	switch command {
+	case telnetWill:
+		if option == telnetOptionEcho || option == telnetOptionSuppressGoAhead {
+			return []byte{telnetIAC, telnetDo, option}
+		}
+		return []byte{telnetIAC, telnetDont, option}
+	case telnetDo:
+		if option == telnetOptionSuppressGoAhead {
+			return []byte{telnetIAC, telnetWill, option}
+		}
+		return []byte{telnetIAC, telnetWont, option}
+	default:
+		// WONT and DONT need no answer once we never asked for the option
+		return nil
+	}
+}
+
+// login answers the username and password prompts
+func (c *telnetConn) login(t TransportConfig) error {
+	loginPrompt := regexp.MustCompile(defaultLoginPrompt)
+	if t.LoginPrompt != "" {
+		loginPrompt = regexp.MustCompile(t.LoginPrompt)
+	}
+	passwordPrompt := regexp.MustCompile(defaultPasswordPrompt)
+	if t.PasswordPrompt != "" {
+		passwordPrompt = regexp.MustCompile(t.PasswordPrompt)
+	}
+
+	if err := c.SetReadDeadline(time.Now().Add(t.connectTimeout())); err != nil {
+		return err
+	}
+	defer c.SetReadDeadline(time.Time{})
+
+	// Devices that only ask for a password skip the username
+	usernameSent := t.Username == ""
+	var received string
+	buffer := make([]byte, 256)
+
+	for {
+		n, err := c.Read(buffer)
+		if err != nil {
+			return fmt.Errorf("waiting for prompt, received %q: %w", received, err)
+		}
+		received += string(buffer[:n])
+
+		switch {
+		case !usernameSent && loginPrompt.MatchString(received):
+			if _, err := c.Write([]byte(t.Username + "\r\n")); err != nil {
+				return err
+			}
+			usernameSent = true
+			received = ""
+			if t.Password == "" {
+				return nil
+			}
+		case passwordPrompt.MatchString(received):
+			if _, err := c.Write([]byte(t.Password + "\r\n")); err != nil {
+				return err
+			}
+			log.Println("Telnet login sent")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package serialhandler
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTelnetPipe returns a telnetConn and the device end of an in-memory connection
+func newTelnetPipe(t *testing.T) (*telnetConn, net.Conn) {
+	t.Helper()
+	client, device := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		device.Close()
+	})
+	return &telnetConn{Conn: client}, device
+}
+
+// readN reads exactly n bytes from the device end, giving up after a second
+func readN(device net.Conn, n int) (string, error) {
+	device.SetReadDeadline(time.Now().Add(time.Second))
+	buffer := make([]byte, n)
+	_, err := io.ReadFull(device, buffer)
+	return string(buffer), err
+}
+
+func TestTelnetReadStripsNegotiation(t *testing.T) {
+	conn, device := newTelnetPipe(t)
+
+	// Negotiation split across writes, an escaped 0xFF and a subnegotiation.
+	// A chunk holds at most one request, the pipe blocks until it is answered.
+	chunks := []struct {
+		data  string
+		reply bool
+	}{
+		{"ab\xff", false},
+		{"\xffc\xff\xfb", false},
+		{"\x01d", true},
+		{"\xff\xfa\x18\x01\xff\xf0e\xff\xfd\x18", true},
+		{"\xff\xfd\x03", true},
+		{"\xff\xfb\x18f", true},
+	}
+	replies := make(chan string, 1)
+	go func() {
+		var received string
+		for _, chunk := range chunks {
+			device.Write([]byte(chunk.data))
+			if chunk.reply {
+				reply, err := readN(device, 3)
+				if err != nil {
+					break
+				}
+				received += reply
+			}
+		}
+		replies <- received
+	}()
+
+	var data string
+	buffer := make([]byte, 64)
+	for len(data) < len("ab\xffcdef") {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buffer)
+		if err != nil {
+			t.Fatalf("Read after %q: %v", data, err)
+		}
+		data += string(buffer[:n])
+	}
+	if data != "ab\xffcdef" {
+		t.Errorf("data = %q, want %q", data, "ab\xffcdef")
+	}
+
+	// WILL ECHO is accepted, DO TERMINAL-TYPE refused, DO SUPPRESS-GO-AHEAD
+	// accepted and WILL TERMINAL-TYPE refused
+	want := "\xff\xfd\x01" + "\xff\xfc\x18" + "\xff\xfb\x03" + "\xff\xfe\x18"
+	select {
+	case got := <-replies:
+		if got != want {
+			t.Errorf("negotiation replies = %q, want %q", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the negotiation replies were not sent")
+	}
+}
+
+func TestTelnetNegotiate(t *testing.T) {
+	tests := []struct {
+		command, option byte
+		reply           string
+	}{
+		{telnetWill, telnetOptionEcho, "\xff\xfd\x01"},
+		{telnetWill, telnetOptionSuppressGoAhead, "\xff\xfd\x03"},
+		{telnetWill, 24, "\xff\xfe\x18"},
+		{telnetDo, telnetOptionSuppressGoAhead, "\xff\xfb\x03"},
+		{telnetDo, telnetOptionEcho, "\xff\xfc\x01"},
+		{telnetDo, 31, "\xff\xfc\x1f"},
+		{telnetWont, telnetOptionEcho, ""},
+		{telnetDont, telnetOptionSuppressGoAhead, ""},
+	}
+	conn := &telnetConn{}
+	for _, test := range tests {
+		if reply := string(conn.negotiate(test.command, test.option)); reply != test.reply {
+			t.Errorf("negotiate(%d, %d) = %q, want %q", test.command, test.option, reply, test.reply)
+		}
+	}
+}
+
+func TestTelnetWriteEscapesIAC(t *testing.T) {
+	conn, device := newTelnetPipe(t)
+
+	written := make(chan error, 1)
+	var n int
+	go func() {
+		var err error
+		n, err = conn.Write([]byte("a\xffb"))
+		written <- err
+	}()
+	data, err := readN(device, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if data != "a\xff\xffb" || n != 3 {
+		t.Errorf("wrote %q and returned %d, want %q and 3", data, n, "a\xff\xffb")
+	}
+}
+
+func TestTelnetLogin(t *testing.T) {
+	tests := []struct {
+		name      string
+		transport TransportConfig
+		device    []string
+		want      string
+	}{
+		{
+			name:      "username and password",
+			transport: TransportConfig{Username: "admin", Password: "secret"},
+			device:    []string{"Welcome\r\nLogin: ", "Password: "},
+			want:      "admin\r\nsecret\r\n",
+		},
+		{
+			name:      "password only",
+			transport: TransportConfig{Password: "secret"},
+			device:    []string{"Password:"},
+			want:      "secret\r\n",
+		},
+		{
+			name:      "username only",
+			transport: TransportConfig{Username: "admin"},
+			device:    []string{"username: "},
+			want:      "admin\r\n",
+		},
+		{
+			name:      "custom prompts",
+			transport: TransportConfig{Username: "admin", Password: "secret", LoginPrompt: `User>$`, PasswordPrompt: `Pass>$`},
+			device:    []string{"User>", "Pass>"},
+			want:      "admin\r\nsecret\r\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, device := newTelnetPipe(t)
+
+			received := make(chan string, 1)
+			go func() {
+				var all string
+				for _, prompt := range test.device {
+					// The prompt arrives with a WILL ECHO in front, which gets a DO ECHO back
+					device.Write([]byte("\xff\xfb\x01" + prompt))
+					reply, err := readN(device, 3)
+					if err != nil || reply != "\xff\xfd\x01" {
+						break
+					}
+					line := ""
+					for !strings.HasSuffix(line, "\r\n") {
+						b, err := readN(device, 1)
+						if err != nil {
+							received <- all + line
+							return
+						}
+						line += b
+					}
+					all += line
+				}
+				received <- all
+			}()
+
+			if err := conn.login(test.transport); err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if got := <-received; got != test.want {
+				t.Errorf("sent %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTelnetLoginTimeout(t *testing.T) {
+	conn, device := newTelnetPipe(t)
+	go device.Write([]byte("Welcome\r\n"))
+
+	start := time.Now()
+	err := conn.login(TransportConfig{Username: "admin", Password: "secret", ConnectTimeoutMs: 50})
+	if !errors.Is(err, os.ErrDeadlineExceeded) || !strings.Contains(err.Error(), "Welcome") {
+		t.Errorf("login error = %v, want a deadline error with the received text", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("login gave up after %v, want about 50ms", elapsed)
+	}
+}
@@ -0,0 +1,94 @@
+package serialhandler
+
+import (
+	"fmt"
+	"net"
+)
+
+// WakeOnLanTarget is one network card that can be woken with a magic packet
+type WakeOnLanTarget struct {
+	Name       string `json:"name"`
+	MACAddress string `json:"mac_address"`
+	// Broadcast is the address the packet is sent to, either an IP or a subnet
+	// like "192.168.10.0/24" for its directed broadcast. Defaults to the
+	// broadcast of Interface, or 255.255.255.255.
+	Broadcast string `json:"broadcast"`
+	// Interface is the network interface the packet is sent from, e.g. "eth1"
+	Interface string `json:"interface"`
+	// Port is 9 by default, some cards listen on 7
+	Port int `json:"port"`
+	// Password is the SecureOn password, "aa:bb:cc:dd:ee:ff" or an IPv4 address
+	Password string `json:"password"`
+}
+
+// WakeOnLanTargets returns the configured targets. Configs without a
+// "wake_on_lan" list get one target "tv" from tv_macaddress and tv_broadcast_ip.
+func (c Config) WakeOnLanTargets() []WakeOnLanTarget {
+	if len(c.WakeOnLan) > 0 {
+		return c.WakeOnLan
+	}
+	if c.TVMacAddress == "" {
+		return nil
+	}
+	return []WakeOnLanTarget{{Name: "tv", MACAddress: c.TVMacAddress, Broadcast: c.TVBroadcastIP}}
+}
+
+// validateWakeOnLan catches bad addresses at startup
+func (c Config) validateWakeOnLan() error {
+	names := map[string]bool{}
+	for _, target := range c.WakeOnLan {
+		if target.Name == "" {
+			return fmt.Errorf("wake_on_lan target without a name")
+		}
+		if names[target.Name] {
+			return fmt.Errorf("duplicate wake_on_lan target %q", target.Name)
+		}
+		names[target.Name] = true
+
+		if mac, err := net.ParseMAC(target.MACAddress); err != nil || len(mac) != 6 {
+			return fmt.Errorf("wake_on_lan target %q: invalid mac_address %q", target.Name, target.MACAddress)
+		}
+		if target.Broadcast != "" && net.ParseIP(target.Broadcast) == nil {
+			if _, _, err := net.ParseCIDR(target.Broadcast); err != nil {
+				return fmt.Errorf("wake_on_lan target %q: broadcast %q is neither an IP nor a subnet", target.Name, target.Broadcast)
+			}
+		}
+		if target.Port < 0 || target.Port > 65535 {
+			return fmt.Errorf("wake_on_lan target %q: invalid port %d", target.Name, target.Port)
+		}
+		if _, err := SecureOnPassword(target.Password); err != nil {
+			return fmt.Errorf("wake_on_lan target %q: %w", target.Name, err)
+		}
+	}
+
+	for _, name := range c.Display.Targets {
+		if _, ok := c.WakeOnLanTarget(name); !ok {
+			return fmt.Errorf("display targets unknown wake_on_lan target %q", name)
+		}
+	}
+	return nil
+}
+
+// WakeOnLanTarget looks up a target by name
+func (c Config) WakeOnLanTarget(name string) (WakeOnLanTarget, bool) {
+	for _, target := range c.WakeOnLanTargets() {
+		if target.Name == name {
+			return target, true
+		}
+	}
+	return WakeOnLanTarget{}, false
+}
+
+// SecureOnPassword decodes a SecureOn password, 6 bytes in MAC notation or 4 as an IPv4 address
+func SecureOnPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	if mac, err := net.ParseMAC(password); err == nil && len(mac) == 6 {
+		return mac, nil
+	}
+	if ip := net.ParseIP(password).To4(); ip != nil {
+		return ip, nil
+	}
+	return nil, fmt.Errorf("invalid SecureOn password, expected aa:bb:cc:dd:ee:ff or an IPv4 address")
+}
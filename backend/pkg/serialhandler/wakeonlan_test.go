@@ -0,0 +1,30 @@
+package serialhandler
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSecureOnPassword(t *testing.T) {
+	tests := []struct {
+		password string
+		want     []byte
+	}{
+		{"", nil},
+		{"01:02:03:04:05:06", []byte{1, 2, 3, 4, 5, 6}},
+		{"0a-0b-0c-0d-0e-0f", []byte{10, 11, 12, 13, 14, 15}},
+		{"192.168.1.7", []byte{192, 168, 1, 7}},
+	}
+	for _, test := range tests {
+		password, err := SecureOnPassword(test.password)
+		if err != nil || !bytes.Equal(password, test.want) {
+			t.Errorf("SecureOnPassword(%q) = % x, %v, want % x", test.password, password, err, test.want)
+		}
+	}
+
+	for _, password := range []string{"secret", "01:02:03:04:05", "01:02:03:04:05:06:07:08", "192.168.1", "::1"} {
+		if decoded, err := SecureOnPassword(password); err == nil {
+			t.Errorf("SecureOnPassword(%q) = % x, want an error", password, decoded)
+		}
+	}
+}
@@ -7,10 +7,20 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
+// tokenClient gives up on the token endpoint after 30 seconds, callers wait for the token
+var tokenClient = &http.Client{Timeout: 30 * time.Second}
+
 // Microsoft graph API
 func GetAccessToken(clientID, clientSecret, tenantID string) (string, error) {
+	token, _, err := RequestAccessToken(clientID, clientSecret, tenantID)
+	return token, err
+}
+
+// RequestAccessToken is GetAccessToken that also returns how long the token is valid
+func RequestAccessToken(clientID, clientSecret, tenantID string) (string, time.Duration, error) {
 	// Construct the OAuth2 token URL
 	tokenURL := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenantID)
 
@@ -23,25 +33,26 @@ func GetAccessToken(clientID, clientSecret, tenantID string) (string, error) {
 	}
 
 	// Create the HTTP POST request
-	resp, err := http.Post(tokenURL, "application/x-www-form-urlencoded", strings.NewReader(formData.Encode()))
+	resp, err := tokenClient.Post(tokenURL, "application/x-www-form-urlencoded", strings.NewReader(formData.Encode()))
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return "", 0, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Check if the status code indicates success
 	if resp.StatusCode != http.StatusOK {
 		body, _ := ioutil.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to get access token: %s, response: %s", resp.Status, string(body))
+		return "", 0, fmt.Errorf("failed to get access token: %s, response: %s", resp.Status, string(body))
 	}
 
 	// Parse the response body for the access token
 	var tokenResponse struct {
 		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
-		return "", fmt.Errorf("failed to decode token response: %w", err)
+		return "", 0, fmt.Errorf("failed to decode token response: %w", err)
 	}
 
-	return tokenResponse.AccessToken, nil
+	return tokenResponse.AccessToken, time.Duration(tokenResponse.ExpiresIn) * time.Second, nil
 }